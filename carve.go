@@ -0,0 +1,306 @@
+package evtxparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// carved records must have a time within this range
+var (
+	carveMinTime = time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	carveMaxTime = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+const carveBlockSize = 1 << 20
+
+// Carver finds event records in arbitrary data, like chunk slack space,
+// unallocated clusters, page files or memory dumps. A candidate is accepted
+// when its size matches the trailing size copy, its time is sane and its
+// BinXML stream decodes.
+//
+// Names and templates are referenced by chunk offset. Without a chunk the
+// start of the chunk is derived from the first inline name or template of
+// a record, later records of the same chunk reuse it.
+type Carver struct {
+	scanner
+
+	ch *Chunk
+
+	// start of the chunk of the last carved record, -1 when unknown
+	base int64
+
+	record *Record
+}
+
+// NewCarver returns a carver over the first size bytes of r.
+func NewCarver(r io.ReaderAt, size int64) *Carver {
+	return &Carver{
+		scanner: scanner{
+			r:    r,
+			size: size,
+		},
+		base: -1,
+	}
+}
+
+// CarveSlack returns a carver over the free space of the chunk following its
+// last record. Records are decoded with the names and templates of the
+// chunk.
+func (ch *Chunk) CarveSlack() *Carver {
+	c := NewCarver(bytes.NewReader(ch.data), int64(len(ch.data)))
+	c.ch = ch
+
+	c.offset = int64(ch.Header.PtrToNext)
+	if c.offset < 512 || c.offset > c.size {
+		c.offset = 512
+	}
+
+	return c
+}
+
+// Next advances to the next carved record, it returns false at the end of
+// the data or when a read failed.
+func (c *Carver) Next() bool {
+	for c.err == nil {
+		p := c.find(MagicAuditRecord)
+		if p < 0 {
+			return false
+		}
+
+		c.offset = p + 1
+
+		if r := c.carve(p); r != nil {
+			c.offset = p + int64(r.Length)
+			c.record = r
+			return true
+		}
+	}
+
+	return false
+}
+
+// Record returns the current record. For a carver from NewCarver its Offset
+// is the offset in the data and Chunk is -1. For a carver from CarveSlack
+// Offset is the absolute file offset, like records read from the chunk, and
+// Chunk is the index of the chunk.
+func (c *Carver) Record() *Record {
+	return c.record
+}
+
+// Err returns the first read error.
+func (c *Carver) Err() error {
+	return c.err
+}
+
+// scanner searches the data for signatures block by block.
+type scanner struct {
+	r    io.ReaderAt
+	size int64
+
+	offset int64
+	block  []byte
+	start  int64
+
+	err error
+}
+
+// find returns the offset of the next occurrence of magic from the current
+// offset, or -1.
+func (s *scanner) find(magic []byte) int64 {
+	for s.offset < s.size {
+		if s.offset < s.start || s.offset+int64(len(magic)) > s.start+int64(len(s.block)) {
+			s.start = s.offset
+			s.block = s.read(s.offset, carveBlockSize)
+			if s.err != nil || len(s.block) < len(magic) {
+				return -1
+			}
+		}
+
+		if i := bytes.Index(s.block[s.offset-s.start:], magic); i >= 0 {
+			return s.offset + int64(i)
+		}
+
+		// the magic may straddle the end of the block
+		s.offset = s.start + int64(len(s.block)-len(magic)+1)
+	}
+
+	return -1
+}
+
+// read returns up to n bytes at offset, less at the end of the data.
+func (s *scanner) read(offset int64, n int) []byte {
+	if offset < 0 || offset >= s.size {
+		return nil
+	}
+
+	if int64(n) > s.size-offset {
+		n = int(s.size - offset)
+	}
+
+	buff := make([]byte, n)
+	if m, err := s.r.ReadAt(buff, offset); m < n {
+		if err == nil || err == io.EOF {
+			return buff[:m]
+		}
+
+		s.err = err
+		return nil
+	}
+
+	return buff
+}
+
+// carve validates and decodes the candidate record at p.
+func (c *Carver) carve(p int64) *Record {
+	hdr := c.read(p, 40)
+	if len(hdr) < 24 {
+		return nil
+	}
+
+	length := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+	if length < minRecordSize || length > ChunkSize || p+length > c.size {
+		return nil
+	}
+
+	ft := FileTime(binary.LittleEndian.Uint64(hdr[16:24]))
+	if ft.IsZero() || ft.IsNever() || ft.Time().Before(carveMinTime) || ft.Time().After(carveMaxTime) {
+		return nil
+	}
+
+	if trailer := c.read(p+length-4, 4); len(trailer) != 4 || int64(binary.LittleEndian.Uint32(trailer)) != length {
+		return nil
+	}
+
+	if c.ch != nil {
+		// records may not extend into the following data, names and
+		// templates from slack never end up in the chunk
+		ch := c.ch.scratch()
+		ch.data = ch.data[:p+length]
+		return decodeCarved(ch, p)
+	}
+
+	for _, base := range c.bases(p, hdr[24:]) {
+		if base < 0 || base > p || p+length-base > ChunkSize {
+			continue
+		}
+
+		ch := &Chunk{
+			Index:     -1,
+			Offset:    base,
+			Strings:   map[uint32]*StringStructure{},
+			Templates: map[uint32]*TemplateDefinition{},
+			data:      c.read(base, int(p+length-base)),
+		}
+
+		if r := decodeCarved(ch, p-base); r != nil {
+			c.base = base
+			return r
+		}
+	}
+
+	return nil
+}
+
+// bases returns the candidate chunk starts of the record at p. The first
+// name or template pointer of a record points directly behind itself when
+// the data follows inline.
+func (c *Carver) bases(p int64, stream []byte) []int64 {
+	bases := []int64{}
+
+	i := 0
+	if len(stream) > 0 && stream[0] == 0x0f {
+		i = 4
+	}
+
+	// offset of the pointer: template id or dependency id and length
+	ptr := -1
+	if len(stream) > i {
+		switch stream[i] {
+		case 0x0c:
+			ptr = i + 6
+		case 0x01, 0x41:
+			ptr = i + 7
+		}
+	}
+
+	if ptr >= 0 && len(stream) >= ptr+4 {
+		bases = append(bases, p+24+int64(ptr)+4-int64(binary.LittleEndian.Uint32(stream[ptr:])))
+	}
+
+	if c.base >= 0 {
+		bases = append(bases, c.base)
+	}
+
+	return bases
+}
+
+func decodeCarved(ch *Chunk, offset int64) *Record {
+	r, err := ch.Record(int(offset))
+	if err != nil || r.Stream == nil || r.Stream.Document() == nil {
+		return nil
+	}
+
+	return r
+}
+
+// ChunkCarver finds chunks in arbitrary data, like unallocated clusters or
+// memory dumps. A candidate is accepted when it is complete and matches its
+// header checksum, it is decoded standalone with its own string and
+// template tables.
+type ChunkCarver struct {
+	scanner
+
+	chunk *Chunk
+}
+
+// NewChunkCarver returns a chunk carver over the first size bytes of r.
+func NewChunkCarver(r io.ReaderAt, size int64) *ChunkCarver {
+	return &ChunkCarver{
+		scanner: scanner{
+			r:    r,
+			size: size,
+		},
+	}
+}
+
+// Next advances to the next carved chunk, it returns false at the end of the
+// data or when a read failed.
+func (c *ChunkCarver) Next() bool {
+	for c.err == nil {
+		p := c.find(MagicElfChunk)
+		if p < 0 {
+			return false
+		}
+
+		c.offset = p + 1
+
+		data := c.read(p, ChunkSize)
+		if len(data) != ChunkSize || checkChunk(data, -1, p) != nil {
+			continue
+		}
+
+		ch, err := decodeChunk(data, -1, p)
+		if err != nil {
+			continue
+		}
+
+		c.offset = p + ChunkSize
+		c.chunk = ch
+		return true
+	}
+
+	return false
+}
+
+// Chunk returns the current chunk. Its Offset is the offset in the data and
+// its Index is -1.
+func (c *ChunkCarver) Chunk() *Chunk {
+	return c.chunk
+}
+
+// Err returns the first read error.
+func (c *ChunkCarver) Err() error {
+	return c.err
+}
@@ -0,0 +1,141 @@
+package evtxparser
+
+import (
+	"bytes"
+	"testing"
+)
+
+// carveRecords returns the record ids of a carver and checks their offsets.
+func carveRecords(t *testing.T, c *Carver, offsets map[uint64]int64) []uint64 {
+	ids := []uint64{}
+
+	for c.Next() {
+		r := c.Record()
+		ids = append(ids, r.RecordID)
+
+		if offset, ok := offsets[r.RecordID]; ok && r.Offset != offset {
+			t.Errorf("record %d at offset %#x, want %#x", r.RecordID, r.Offset, offset)
+		}
+	}
+
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
+
+func equalIDs(a []uint64, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fixtureRecords returns the chunk offsets of the records of the fixture.
+func fixtureRecords(t *testing.T) map[uint64]int64 {
+	f := openFixture(t, readFixture(t, "records.evtx"))
+
+	offsets := map[uint64]int64{}
+
+	it := f.Records()
+	for it.Next() {
+		offsets[it.Record().RecordID] = it.Record().Offset - HeaderSize
+	}
+
+	if err := it.Err(); err != nil || len(offsets) != 11 {
+		t.Fatalf("fixture has %d records: %v", len(offsets), err)
+	}
+
+	return offsets
+}
+
+// TestCarveSlack carves the last three records of a chunk whose free space
+// offset has been moved in front of them.
+func TestCarveSlack(t *testing.T) {
+	f := openFixture(t, readFixture(t, "slack.evtx"))
+
+	ch, err := f.Chunk(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []uint64{}
+
+	it := ch.Records()
+	for it.Next() {
+		ids = append(ids, it.Record().RecordID)
+	}
+
+	if want := []uint64{1, 2, 3, 4, 5, 6, 7, 8}; !equalIDs(ids, want) {
+		t.Fatalf("got records %v, want %v", ids, want)
+	}
+
+	strings, templates := len(ch.Strings), len(ch.Templates)
+
+	offsets := map[uint64]int64{}
+	for id, offset := range fixtureRecords(t) {
+		offsets[id] = HeaderSize + offset
+	}
+
+	c := ch.CarveSlack()
+	if ids := carveRecords(t, c, offsets); !equalIDs(ids, []uint64{9, 10, 11}) {
+		t.Errorf("carved records %v, want [9 10 11]", ids)
+	}
+
+	if c.Record().Chunk != 0 {
+		t.Errorf("carved record of chunk %d, want 0", c.Record().Chunk)
+	}
+
+	if len(ch.Strings) != strings || len(ch.Templates) != templates {
+		t.Errorf("carving modified the chunk")
+	}
+}
+
+// TestCarveBlockBoundary carves a chunk without file header from raw data,
+// with a record across the boundary of the blocks that are searched.
+func TestCarveBlockBoundary(t *testing.T) {
+	chunk := readFixture(t, "records.evtx")[HeaderSize:]
+	offsets := fixtureRecords(t)
+
+	// record 2 is wiped, the search for record 3 runs into the boundary
+	// instead of continuing at the end of the previous record
+	want := []uint64{1, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+
+	tests := []struct {
+		name string
+		// position of record 3 relative to the block boundary
+		at int64
+	}{
+		{"magic across boundary", -2},
+		{"record across boundary", -100},
+		{"record at boundary", 0},
+	}
+
+	for _, test := range tests {
+		start := carveBlockSize + test.at - offsets[3]
+
+		// filler with a false signature in front of the chunk
+		data := make([]byte, int(start)+len(chunk)+4096)
+		copy(data[start-64:], MagicAuditRecord)
+		copy(data[start:], chunk)
+		copy(data[start+offsets[2]:], make([]byte, len(MagicAuditRecord)))
+
+		abs := map[uint64]int64{}
+		for id, offset := range offsets {
+			abs[id] = start + offset
+		}
+
+		c := NewCarver(bytes.NewReader(data), int64(len(data)))
+		if ids := carveRecords(t, c, abs); !equalIDs(ids, want) {
+			t.Errorf("%s: carved records %v, want %v", test.name, ids, want)
+		}
+	}
+}
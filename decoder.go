@@ -107,7 +107,7 @@ func (d *DefaultDecoder) StartOffset() int {
 }
 
 func (d *DefaultDecoder) HasBytes(size int) bool {
-	if len(d.data) >= d.offset+size {
+	if d.offset >= 0 && size >= 0 && len(d.data) >= d.offset+size {
 		return true
 	}
 
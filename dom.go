@@ -0,0 +1,409 @@
+package evtxparser
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Node is an Element, Text, CData, Entity or ProcInst of a resolved
+// document.
+type Node interface {
+	String() string
+}
+
+// Element is an element of a resolved document, substitutions in its
+// attributes and content are replaced by their values.
+type Element struct {
+	Name     string
+	Attrs    []*Attr
+	Children []Node
+}
+
+// Attr is an attribute of an element. Value holds the typed substitution
+// value, or a string for literal text.
+type Attr struct {
+	Name  string
+	Value interface{}
+}
+
+func (a *Attr) String() string {
+	return formatValue(a.Value)
+}
+
+// Text is character data of an element. Value holds the typed substitution
+// value, or a string for literal text.
+type Text struct {
+	Value interface{}
+}
+
+func (t *Text) String() string {
+	return formatValue(t.Value)
+}
+
+// CData is a CDATA section.
+type CData struct {
+	Text string
+}
+
+func (c *CData) String() string {
+	return c.Text
+}
+
+// Entity is a reference to a named entity.
+type Entity struct {
+	Name string
+}
+
+var predefinedEntities = map[string]string{
+	"amp":  "&",
+	"lt":   "<",
+	"gt":   ">",
+	"quot": `"`,
+	"apos": "'",
+}
+
+// String returns the replacement text of predefined entities and the
+// reference itself otherwise.
+func (en *Entity) String() string {
+	if v, ok := predefinedEntities[en.Name]; ok {
+		return v
+	}
+
+	return "&" + en.Name + ";"
+}
+
+// ProcInst is a processing instruction.
+type ProcInst struct {
+	Target string
+	Data   string
+}
+
+func (p *ProcInst) String() string {
+	return ""
+}
+
+// Attr returns the attribute with the given name, or nil.
+func (e *Element) Attr(name string) *Attr {
+	for _, a := range e.Attrs {
+		if a.Name == name {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// Elements returns the child elements.
+func (e *Element) Elements() []*Element {
+	elements := []*Element{}
+	for _, child := range e.Children {
+		if ce, ok := child.(*Element); ok {
+			elements = append(elements, ce)
+		}
+	}
+
+	return elements
+}
+
+// Find returns the first element matching the slash separated path of
+// element names, relative to e.
+func (e *Element) Find(path string) *Element {
+	if elements := e.FindAll(path); len(elements) > 0 {
+		return elements[0]
+	}
+
+	return nil
+}
+
+// FindAll returns all elements matching the slash separated path of element
+// names, relative to e.
+func (e *Element) FindAll(path string) []*Element {
+	elements := []*Element{e}
+
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+
+		matches := []*Element{}
+		for _, parent := range elements {
+			for _, ce := range parent.Elements() {
+				if ce.Name == name {
+					matches = append(matches, ce)
+				}
+			}
+		}
+
+		elements = matches
+	}
+
+	return elements
+}
+
+// Value returns the typed value of an element with a single text node, or
+// its text otherwise.
+func (e *Element) Value() interface{} {
+	if len(e.Children) == 0 {
+		return nil
+	}
+
+	if t, ok := e.Children[0].(*Text); ok && len(e.Children) == 1 {
+		return t.Value
+	}
+
+	return e.Text()
+}
+
+// Text returns the concatenated character data of the element and its
+// descendants.
+func (e *Element) Text() string {
+	parts := []string{}
+	for _, child := range e.Children {
+		parts = append(parts, child.String())
+	}
+
+	return strings.Join(parts, "")
+}
+
+func (e *Element) String() string {
+	return e.Text()
+}
+
+// Document returns the resolved document of the stream, or nil when its
+// template could not be resolved.
+func (s *Stream) Document() *Element {
+	if s.ElementNode != nil {
+		return s.ElementNode.Element(s.SubstitutionArray)
+	}
+
+	if s.TemplateDefinition == nil || s.TemplateDefinition.ElementNode == nil {
+		return nil
+	}
+
+	return s.TemplateDefinition.ElementNode.Element(s.SubstitutionArray)
+}
+
+// Element resolves the element node with the values of sa. It returns nil
+// when the element is omitted because its content consists of optional
+// substitutions without a value.
+func (s *ElementNode) Element(sa SubstitutionArray) *Element {
+	if elements := s.elements(sa); len(elements) > 0 {
+		return elements[0]
+	}
+
+	return nil
+}
+
+// elements resolves the element node, like Windows the element is repeated
+// for every item when its content is an array value.
+func (s *ElementNode) elements(sa SubstitutionArray) []*Element {
+	count := -1
+
+	if s.Children != nil {
+		for _, child := range *s.Children {
+			if sub, ok := child.(*Substitution); ok && isArrayValue(sa[uint32(sub.Index)]) {
+				count = reflect.ValueOf(sa[uint32(sub.Index)]).Len()
+				break
+			}
+		}
+	}
+
+	if count <= 0 {
+		if e := s.element(sa, -1); e != nil {
+			return []*Element{e}
+		}
+
+		return nil
+	}
+
+	elements := []*Element{}
+	for i := 0; i < count; i++ {
+		if e := s.element(sa, i); e != nil {
+			elements = append(elements, e)
+		}
+	}
+
+	return elements
+}
+
+// element resolves the element node, array values in its content are
+// replaced by their item when item is not -1.
+func (s *ElementNode) element(sa SubstitutionArray, item int) *Element {
+	e := &Element{}
+
+	if s.StringStructure != nil {
+		e.Name = s.StringStructure.String()
+	}
+
+	if s.Attributes != nil {
+		e.Attrs = s.Attributes.resolve(sa)
+	}
+
+	if s.Children == nil {
+		return e
+	}
+
+	omit := false
+
+	for _, child := range *s.Children {
+		switch child := child.(type) {
+		case *ElementNode:
+			for _, ce := range child.elements(sa) {
+				e.Children = append(e.Children, ce)
+			}
+		case *Value:
+			e.Children = append(e.Children, &Text{
+				Value: child.String(),
+			})
+		case *Substitution:
+			v := sa[uint32(child.Index)]
+			if item != -1 && isArrayValue(v) {
+				v = arrayItem(v, item)
+			}
+
+			// nested streams are part of the document
+			if stream, ok := v.(Stream); ok {
+				if doc := stream.Document(); doc != nil {
+					e.Children = append(e.Children, doc)
+					continue
+				}
+
+				v = nil
+			}
+
+			if isEmptyValue(v) {
+				omit = omit || child.Optional
+				continue
+			}
+
+			e.Children = append(e.Children, &Text{
+				Value: v,
+			})
+		case *CDataSection:
+			e.Children = append(e.Children, &CData{
+				Text: child.String(),
+			})
+		case *CharRef:
+			e.Children = append(e.Children, &Text{
+				Value: child.String(),
+			})
+		case *EntityRef:
+			e.Children = append(e.Children, &Entity{
+				Name: child.Name(),
+			})
+		case *ProcessingInstruction:
+			pi := &ProcInst{
+				Data: utf16String(child.Data),
+			}
+
+			if child.Target != nil {
+				pi.Target = child.Target.String()
+			}
+
+			e.Children = append(e.Children, pi)
+		}
+	}
+
+	if omit && len(e.Children) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// isArrayValue reports whether v is the value of an array substitution,
+// binary data is a single value.
+func isArrayValue(v interface{}) bool {
+	if _, ok := v.([]byte); ok || v == nil {
+		return false
+	}
+
+	return reflect.TypeOf(v).Kind() == reflect.Slice
+}
+
+// arrayItem returns item i of an array value, or nil when it has fewer items.
+func arrayItem(v interface{}, i int) interface{} {
+	rv := reflect.ValueOf(v)
+	if i >= rv.Len() {
+		return nil
+	}
+
+	return rv.Index(i).Interface()
+}
+
+// isEmptyValue reports whether a substitution value is null or has no data.
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice:
+		return rv.Len() == 0
+	}
+
+	return false
+}
+
+func (s *Attributes) resolve(sa SubstitutionArray) []*Attr {
+	attrs := []*Attr{}
+
+	for _, attribute := range *s {
+		a := &Attr{}
+
+		if attribute.StringStructure != nil {
+			a.Name = attribute.StringStructure.String()
+		}
+
+		// a single substitution keeps its type, anything else is text
+		if len(attribute.Children) == 1 {
+			if sub, ok := attribute.Children[0].(*Substitution); ok {
+				a.Value = sa[uint32(sub.Index)]
+
+				if sub.Optional && isEmptyValue(a.Value) {
+					continue
+				}
+
+				attrs = append(attrs, a)
+				continue
+			}
+		}
+
+		parts := []string{}
+		omit := false
+
+		for _, child := range attribute.Children {
+			switch child := child.(type) {
+			case *Value:
+				parts = append(parts, child.String())
+			case *Substitution:
+				v := sa[uint32(child.Index)]
+				if isEmptyValue(v) {
+					omit = omit || child.Optional
+					continue
+				}
+
+				parts = append(parts, formatValue(v))
+			case *CharRef:
+				parts = append(parts, child.String())
+			case *EntityRef:
+				en := &Entity{
+					Name: child.Name(),
+				}
+
+				parts = append(parts, en.String())
+			}
+		}
+
+		a.Value = strings.Join(parts, "")
+
+		if omit && a.Value == "" {
+			continue
+		}
+
+		attrs = append(attrs, a)
+	}
+
+	return attrs
+}
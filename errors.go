@@ -0,0 +1,154 @@
+package evtxparser
+
+import "fmt"
+
+// ErrBadMagic is returned when a file header, chunk header or record does
+// not start with its signature. Chunk is -1 for the file header.
+type ErrBadMagic struct {
+	Chunk    int
+	Offset   int64
+	RecordID uint64
+
+	Magic []byte
+}
+
+func (e ErrBadMagic) Error() string {
+	return fmt.Sprintf("bad magic % x at chunk %d offset %#x (record %d)", e.Magic, e.Chunk, e.Offset, e.RecordID)
+}
+
+// ErrUnexpectedToken is returned when the BinXML stream contains a token
+// that is not valid at the current position.
+type ErrUnexpectedToken struct {
+	Chunk    int
+	Offset   int64
+	RecordID uint64
+
+	Token uint8
+}
+
+func (e ErrUnexpectedToken) Error() string {
+	return fmt.Sprintf("unexpected token %#02x at chunk %d offset %#x (record %d)", e.Token, e.Chunk, e.Offset, e.RecordID)
+}
+
+// ErrTruncated is returned when a structure extends beyond the end of its
+// chunk or file.
+type ErrTruncated struct {
+	Chunk    int
+	Offset   int64
+	RecordID uint64
+
+	Got  int
+	Want int
+}
+
+func (e ErrTruncated) Error() string {
+	return fmt.Sprintf("truncated data at chunk %d offset %#x (record %d): length %v too short, %v required", e.Chunk, e.Offset, e.RecordID, e.Got, e.Want)
+}
+
+// ErrRecordSize is returned when the size of a record does not match the
+// copy in its last 4 bytes, or its stream extends into the copy.
+type ErrRecordSize struct {
+	Chunk    int
+	Offset   int64
+	RecordID uint64
+
+	Size    uint32
+	Trailer uint32
+}
+
+func (e ErrRecordSize) Error() string {
+	return fmt.Sprintf("bad record size %d (trailing copy %d) at chunk %d offset %#x (record %d)", e.Size, e.Trailer, e.Chunk, e.Offset, e.RecordID)
+}
+
+// ErrChecksum is returned when a chunk header does not match its checksum.
+type ErrChecksum struct {
+	Chunk  int
+	Offset int64
+
+	Checksum Checksum
+}
+
+func (e ErrChecksum) Error() string {
+	return fmt.Sprintf("checksum mismatch at chunk %d offset %#x: stored %#08x, computed %#08x", e.Chunk, e.Offset, e.Checksum.Stored, e.Checksum.Computed)
+}
+
+// annotate adds the location of a decoding error. Errors are raised with
+// offsets relative to the decoder, base is the absolute offset of its data.
+func annotate(err error, d Decoder, chunk int, base int64, recordID uint64) error {
+	switch e := err.(type) {
+	case ErrBadMagic:
+		e.Chunk, e.Offset, e.RecordID = chunk, base+e.Offset, recordID
+		return e
+	case ErrUnexpectedToken:
+		e.Chunk, e.Offset, e.RecordID = chunk, base+e.Offset, recordID
+		return e
+	case ErrTruncated:
+		e.Chunk, e.Offset, e.RecordID = chunk, base+e.Offset, recordID
+		return e
+	case ErrRecordSize:
+		e.Chunk, e.Offset, e.RecordID = chunk, base+e.Offset, recordID
+		return e
+	case ErrDecoderTooShort:
+		return ErrTruncated{
+			Chunk:    chunk,
+			Offset:   base + int64(d.Offset()),
+			RecordID: recordID,
+			Got:      e.Want,
+			Want:     e.Got,
+		}
+	}
+
+	return err
+}
+
+func expectMagic(d Decoder, magic []byte, buff []byte) bool {
+	offset := d.Offset()
+
+	d.Copy(buff)
+	if d.LastError() != nil {
+		return false
+	}
+
+	if string(buff) == string(magic) {
+		return true
+	}
+
+	d.SetLastError(ErrBadMagic{
+		Offset: int64(offset),
+		Magic:  append([]byte{}, buff...),
+	})
+	return false
+}
+
+// expect consumes a single token and fails the decoder when it differs from
+// token.
+func expect(d Decoder, token uint8) bool {
+	offset := d.Offset()
+
+	v := d.Uint8()
+	if d.LastError() != nil {
+		return false
+	}
+
+	if v == token {
+		return true
+	}
+
+	d.SetLastError(ErrUnexpectedToken{
+		Offset: int64(offset),
+		Token:  v,
+	})
+	return false
+}
+
+// unexpected fails the decoder on the token at the current offset.
+func unexpected(d Decoder) {
+	if d.LastError() != nil {
+		return
+	}
+
+	d.SetLastError(ErrUnexpectedToken{
+		Offset: int64(d.Offset()),
+		Token:  d.PeekUint8(),
+	})
+}
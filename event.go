@@ -0,0 +1,271 @@
+package evtxparser
+
+import (
+	"strconv"
+	"time"
+)
+
+// Event holds the System section and the event specific data of a record.
+type Event struct {
+	EventID    uint16
+	Qualifiers uint16
+	Version    uint8
+	Level      uint8
+	Task       uint16
+	Opcode     uint8
+	Keywords   uint64
+
+	Provider    Provider
+	TimeCreated time.Time
+
+	EventRecordID uint64
+
+	Correlation Correlation
+	Execution   Execution
+
+	Channel  string
+	Computer string
+
+	// UserID is nil when the event has no security descriptor
+	UserID *Sid
+
+	EventData Fields
+	UserData  Fields
+}
+
+type Provider struct {
+	Name            string
+	Guid            Guid
+	EventSourceName string
+}
+
+type Correlation struct {
+	ActivityID        Guid
+	RelatedActivityID Guid
+}
+
+type Execution struct {
+	ProcessID uint32
+	ThreadID  uint32
+}
+
+// Field is a named value of the EventData or UserData section.
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// Fields is an ordered list of fields.
+type Fields []Field
+
+// Get returns the value of the first field with the given name.
+func (f Fields) Get(name string) (interface{}, bool) {
+	for _, field := range f {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Event returns the typed event of the record, or nil when the record has no
+// resolved document.
+func (r *Record) Event() *Event {
+	if r.Stream == nil {
+		return nil
+	}
+
+	doc := r.Stream.Document()
+	if doc == nil {
+		return nil
+	}
+
+	return NewEvent(doc)
+}
+
+// NewEvent builds an Event from a resolved Event document.
+func NewEvent(doc *Element) *Event {
+	ev := &Event{}
+
+	system := doc.Find("System")
+	if system == nil {
+		system = &Element{}
+	}
+
+	if e := system.Find("Provider"); e != nil {
+		ev.Provider.Name = attrString(e, "Name")
+		ev.Provider.Guid, _ = toGuid(attrValue(e, "Guid"))
+		ev.Provider.EventSourceName = attrString(e, "EventSourceName")
+	}
+
+	if e := system.Find("EventID"); e != nil {
+		v, _ := toUint64(e.Value())
+		ev.EventID = uint16(v)
+
+		v, _ = toUint64(attrValue(e, "Qualifiers"))
+		ev.Qualifiers = uint16(v)
+	}
+
+	ev.Version = uint8(elementUint64(system, "Version"))
+	ev.Level = uint8(elementUint64(system, "Level"))
+	ev.Task = uint16(elementUint64(system, "Task"))
+	ev.Opcode = uint8(elementUint64(system, "Opcode"))
+	ev.Keywords = elementUint64(system, "Keywords")
+
+	if e := system.Find("TimeCreated"); e != nil {
+		ev.TimeCreated, _ = toTime(attrValue(e, "SystemTime"))
+	}
+
+	ev.EventRecordID = elementUint64(system, "EventRecordID")
+
+	if e := system.Find("Correlation"); e != nil {
+		ev.Correlation.ActivityID, _ = toGuid(attrValue(e, "ActivityID"))
+		ev.Correlation.RelatedActivityID, _ = toGuid(attrValue(e, "RelatedActivityID"))
+	}
+
+	if e := system.Find("Execution"); e != nil {
+		v, _ := toUint64(attrValue(e, "ProcessID"))
+		ev.Execution.ProcessID = uint32(v)
+
+		v, _ = toUint64(attrValue(e, "ThreadID"))
+		ev.Execution.ThreadID = uint32(v)
+	}
+
+	if e := system.Find("Channel"); e != nil {
+		ev.Channel = e.Text()
+	}
+
+	if e := system.Find("Computer"); e != nil {
+		ev.Computer = e.Text()
+	}
+
+	if e := system.Find("Security"); e != nil {
+		if sid, ok := toSid(attrValue(e, "UserID")); ok {
+			ev.UserID = &sid
+		}
+	}
+
+	if e := doc.Find("EventData"); e != nil {
+		for _, ce := range e.Elements() {
+			name := attrString(ce, "Name")
+			if name == "" {
+				name = ce.Name
+			}
+
+			ev.EventData = append(ev.EventData, Field{
+				Name:  name,
+				Value: ce.Value(),
+			})
+		}
+	}
+
+	if e := doc.Find("UserData"); e != nil {
+		// UserData holds a single provider defined element
+		for _, root := range e.Elements() {
+			for _, ce := range root.Elements() {
+				ev.UserData = append(ev.UserData, Field{
+					Name:  ce.Name,
+					Value: ce.Value(),
+				})
+			}
+		}
+	}
+
+	return ev
+}
+
+func attrValue(e *Element, name string) interface{} {
+	if a := e.Attr(name); a != nil {
+		return a.Value
+	}
+
+	return nil
+}
+
+func attrString(e *Element, name string) string {
+	if a := e.Attr(name); a != nil {
+		return a.String()
+	}
+
+	return ""
+}
+
+func elementUint64(e *Element, name string) uint64 {
+	if ce := e.Find(name); ce != nil {
+		v, _ := toUint64(ce.Value())
+		return v
+	}
+
+	return 0
+}
+
+func toUint64(v interface{}) (uint64, bool) {
+	switch v := v.(type) {
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case int8:
+		return uint64(v), true
+	case int16:
+		return uint64(v), true
+	case int32:
+		return uint64(v), true
+	case int64:
+		return uint64(v), true
+	case HexInt32:
+		return uint64(v), true
+	case HexInt64:
+		return uint64(v), true
+	case SizeT:
+		return uint64(v), true
+	case string:
+		n, err := strconv.ParseUint(v, 0, 64)
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+func toGuid(v interface{}) (Guid, bool) {
+	switch v := v.(type) {
+	case Guid:
+		return v, true
+	case string:
+		g, err := ParseGuid(v)
+		return g, err == nil
+	}
+
+	return Guid{}, false
+}
+
+func toSid(v interface{}) (Sid, bool) {
+	switch v := v.(type) {
+	case Sid:
+		return v, true
+	case string:
+		sid, err := ParseSid(v)
+		return sid, err == nil
+	}
+
+	return Sid{}, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case FileTime:
+		return v.Time(), true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+
+	return time.Time{}, false
+}
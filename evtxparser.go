@@ -4,8 +4,10 @@ package evtxparser
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"time"
 
 	"golang.org/x/text/encoding/unicode"
@@ -26,11 +28,27 @@ type Header struct {
 	Checksum uint32
 }
 
+const (
+	HeaderFlagDirty = 0x1
+	HeaderFlagFull  = 0x2
+)
+
+// IsDirty reports whether the log was not closed cleanly, the chunk count
+// of a dirty log may be stale.
+func (s *Header) IsDirty() bool {
+	return s.Flags&HeaderFlagDirty != 0
+}
+
+// IsFull reports whether the log reached its maximum size.
+func (s *Header) IsFull() bool {
+	return s.Flags&HeaderFlagFull != 0
+}
+
 func (s *Header) Decode(d Decoder) {
 	buff := [8]byte{}
-	d.Copy(buff[:])
-
-	Assert(bytes.Equal(buff[0:7], []byte("ElfFile")))
+	if !expectMagic(d, MagicElfFile, buff[:]) {
+		return
+	}
 
 	d.Skip(8)
 	s.CurrentChunk = d.Uint64()
@@ -65,7 +83,9 @@ func (s *TemplateDefinition) Dump(sa SubstitutionArray) {
 	s.ElementNode.Dump(sa)
 }
 
-func (s *TemplateDefinition) Decode(d Decoder) {
+func (s *TemplateDefinition) Decode(d Decoder, ch *Chunk) {
+	offset := d.Offset()
+
 	s.Pointer = d.Uint32()
 
 	d.Copy(s.Guid[:])
@@ -76,28 +96,128 @@ func (s *TemplateDefinition) Decode(d Decoder) {
 	d.Skip(3)
 
 	en := &ElementNode{}
-	en.Decode(d)
+	en.Decode(d, ch)
 	s.ElementNode = en
 
-	Assert(d.Uint8() == 0x00)
+	if !expect(d, 0x00) {
+		return
+	}
+
+	ch.Templates[uint32(offset)] = s
 }
 
+// Chunk holds the header of a single chunk and the names and templates
+// decoded from it. BinXML pointers are relative to the chunk they occur in.
 type Chunk struct {
+	Header ChunkHeader
+
+	// hash tables with the first name and template of each bucket
+	StringTable   [64]uint32
+	TemplateTable [32]uint32
+
+	Strings   map[uint32]*StringStructure
+	Templates map[uint32]*TemplateDefinition
+
+	Index  int
+	Offset int64
+
+	data []byte
+
+	// names and templates missing from the maps are looked up in parent
+	parent *Chunk
+}
+
+// scratch returns a chunk over the same data with empty maps that fall back
+// to the maps of ch. Records are decoded with a scratch chunk, so names and
+// templates found while decoding never modify ch and it can be shared
+// between goroutines.
+func (ch *Chunk) scratch() *Chunk {
+	c := *ch
+	c.Strings = map[uint32]*StringStructure{}
+	c.Templates = map[uint32]*TemplateDefinition{}
+	c.parent = ch
+	return &c
+}
+
+func (ch *Chunk) lookupString(ptr uint32) (*StringStructure, bool) {
+	for c := ch; c != nil; c = c.parent {
+		if ss, ok := c.Strings[ptr]; ok {
+			return ss, true
+		}
+	}
+
+	return nil, false
+}
+
+func (ch *Chunk) lookupTemplate(ptr uint32) (*TemplateDefinition, bool) {
+	for c := ch; c != nil; c = c.parent {
+		if t, ok := c.Templates[ptr]; ok {
+			return t, true
+		}
+	}
+
+	return nil, false
 }
 
 func (ch *Chunk) Decode(d Decoder) {
-	hdr := ChunkHeader{}
-	hdr.Decode(d)
+	ch.Strings = map[uint32]*StringStructure{}
+	ch.Templates = map[uint32]*TemplateDefinition{}
+
+	ch.data = d.Data()
+
+	ch.Header.Decode(d)
 
 	// string table
-	d.Skip(256)
+	for i := range ch.StringTable {
+		ch.StringTable[i] = d.Uint32()
+	}
 
 	// template table
-	d.Skip(128)
+	for i := range ch.TemplateTable {
+		ch.TemplateTable[i] = d.Uint32()
+	}
 
-	for i := 0; i < int(hdr.LastRecord-hdr.FirstRecord); i++ {
-		b := AuditRecord{}
-		b.Decode(d)
+	if d.LastError() != nil {
+		return
+	}
+
+	ch.preload(d)
+}
+
+// preload decodes every name and template reachable from the hash tables.
+// The tables are a cache only, a corrupt chain ends the walk of its bucket
+// without failing the chunk.
+func (ch *Chunk) preload(d Decoder) {
+	for _, ptr := range ch.StringTable {
+		for ptr != 0 {
+			if _, ok := ch.lookupString(ptr); ok {
+				break
+			}
+
+			ss := ch.stringAt(d, ptr)
+			if d.LastError() != nil {
+				d.SetLastError(nil)
+				break
+			}
+
+			ptr = ss.NextPtr
+		}
+	}
+
+	for _, ptr := range ch.TemplateTable {
+		for ptr != 0 {
+			if _, ok := ch.lookupTemplate(ptr); ok {
+				break
+			}
+
+			t := ch.templateAt(d, ptr)
+			if d.LastError() != nil {
+				d.SetLastError(nil)
+				break
+			}
+
+			ptr = t.Pointer
+		}
 	}
 }
 
@@ -112,17 +232,20 @@ type ChunkHeader struct {
 	Checksum      uint32
 	PtrToLast     uint32
 	PtrToNext     uint32
+
+	RecordsChecksum uint32
 }
 
 var (
+	MagicElfFile     = []byte{0x45, 0x6c, 0x66, 0x46, 0x69, 0x6c, 0x65, 0x0}
 	MagicElfChunk    = []byte{0x45, 0x6c, 0x66, 0x43, 0x68, 0x6e, 0x6b, 0x0}
 	MagicAuditRecord = []byte{0x2a, 0x2a, 0x0, 0x0}
 )
 
 func (ch *ChunkHeader) Decode(d Decoder) {
-	d.Copy(ch.Magic[:])
-
-	Assert(bytes.Equal(ch.Magic[:], MagicElfChunk))
+	if !expectMagic(d, MagicElfChunk, ch.Magic[:]) {
+		return
+	}
 
 	ch.FirstRecord = d.Uint64()
 	ch.LastRecord = d.Uint64()
@@ -134,7 +257,7 @@ func (ch *ChunkHeader) Decode(d Decoder) {
 	ch.PtrToLast = d.Uint32()
 	ch.PtrToNext = d.Uint32()
 
-	d.Uint32()
+	ch.RecordsChecksum = d.Uint32()
 
 	d.Skip(64)
 
@@ -142,38 +265,97 @@ func (ch *ChunkHeader) Decode(d Decoder) {
 	ch.Checksum = d.Uint32()
 }
 
+// minRecordSize is the size of a record header and trailing size copy.
+const minRecordSize = 28
+
 type AuditRecord struct {
 	Length   uint32
 	RecordID uint64
-	Time     time.Time
+	Time     FileTime
 	Magic    [4]byte
+
+	Stream *Stream
+
+	// Slack is the number of bytes between the end of the stream and the
+	// trailing size copy.
+	Slack uint32
 }
 
-func (ar *AuditRecord) Decode(d Decoder) {
+func (ar *AuditRecord) Decode(d Decoder, ch *Chunk) {
 	start := d.Offset()
-	_ = start
 
-	d.Copy(ar.Magic[:])
-
-	Assert(bytes.Equal(ar.Magic[:], MagicAuditRecord))
+	if !expectMagic(d, MagicAuditRecord, ar.Magic[:]) {
+		return
+	}
 
 	ar.Length = d.Uint32()
 
 	ar.RecordID = d.Uint64()
 
-	lowDateTime := int64(d.Uint32())
-	highDateTime := int64(d.Uint32())
-	nsec := int64(highDateTime)<<32 + int64(lowDateTime)
-	nsec -= 116444736000000000
-	nsec *= 100
-	ar.Time = time.Unix(0, nsec)
+	ar.Time = FileTime(d.Uint64())
+
+	trailer, ok := ar.trailer(d, start)
+	if !ok {
+		return
+	}
+
+	s := &Stream{}
+	s.Decode(d, ch)
+	ar.Stream = s
 
-	s := Stream{}
-	s.Decode(d)
+	if d.LastError() != nil {
+		return
+	}
 
-	s.Dump()
+	// the stream may not extend into the size copy
+	if d.Offset() > start+int(ar.Length)-4 {
+		d.SetLastError(ErrRecordSize{
+			Offset:  int64(start),
+			Size:    ar.Length,
+			Trailer: trailer,
+		})
+		return
+	}
 
-	d.Skip(int(ar.Length) - (d.Offset() - start))
+	ar.Slack = uint32(start + int(ar.Length) - 4 - d.Offset())
+
+	d.Seek(start + int(ar.Length))
+}
+
+// trailer returns the copy of the size in the last 4 bytes of the record,
+// and fails the decoder when the record is truncated or the sizes differ.
+func (ar *AuditRecord) trailer(d Decoder, start int) (uint32, bool) {
+	if d.LastError() != nil {
+		return 0, false
+	}
+
+	if ar.Length < minRecordSize {
+		d.SetLastError(ErrRecordSize{
+			Offset: int64(start),
+			Size:   ar.Length,
+		})
+		return 0, false
+	}
+
+	prev := d.Seek(start)
+	if !d.HasBytes(int(ar.Length)) {
+		return 0, false
+	}
+
+	d.Seek(start + int(ar.Length) - 4)
+	trailer := d.Uint32()
+	d.Seek(prev)
+
+	if trailer != ar.Length {
+		d.SetLastError(ErrRecordSize{
+			Offset:  int64(start),
+			Size:    ar.Length,
+			Trailer: trailer,
+		})
+		return trailer, false
+	}
+
+	return trailer, true
 }
 
 func Decode(Decoder) {
@@ -187,108 +369,119 @@ type ElementNode struct {
 	Children        *Children
 }
 
-func (s *ElementNode) Decode(d Decoder) {
-	Assert(d.PeekUint8() == 0x1 || d.PeekUint8() == 0x41)
+func (s *ElementNode) Decode(d Decoder, ch *Chunk) {
+	if token := d.PeekUint8(); token != 0x1 && token != 0x41 {
+		unexpected(d)
+		return
+	}
 
 	d.Uint8()
 	d.Uint16()
 
 	s.Length = d.Uint32()
 
-	stringPtr := d.Uint32()
-	if (int(stringPtr)) == d.Offset() {
-		ss := &StringStructure{
-			Ptr: stringPtr,
-		}
-		ss.Decode(d)
-		s.StringStructure = ss
-	} else {
-		s.StringStructure = pointers[stringPtr].(*StringStructure)
+	s.StringStructure = ch.decodeString(d)
+
+	if d.LastError() != nil {
+		return
 	}
 
 	if d.PeekUint8() == 0x02 {
 	} else if d.PeekUint8() == 0x03 {
 	} else {
 		aa := &Attributes{}
-		aa.Decode(d)
+		aa.Decode(d, ch)
 		s.Attributes = aa
 	}
 
 	switch d.PeekUint8() {
 	case 0x2:
-		Assert(d.Uint8() == 0x2)
+		d.Uint8()
 
 		// children
 		aa := &Children{}
-		aa.Decode(d)
+		aa.Decode(d, ch)
 		s.Children = aa
 	case 0x3:
-		Assert(d.Uint8() == 0x3)
-
+		d.Uint8()
 	default:
-		d.Dump()
-		panic("Unexpected")
-		// attributes
+		unexpected(d)
 	}
 }
 
 func (s *ElementNode) Dump(sa SubstitutionArray) {
-	if s.StringStructure != nil {
-		fmt.Printf("<%s", s.StringStructure.String())
-	}
-
-	if s.Attributes != nil {
-		s.Attributes.Dump(sa)
-	}
-
-	fmt.Printf(">")
-
-	if s.Children != nil {
-		for _, child := range *s.Children {
-			_ = child
-			if en, ok := child.(*ElementNode); ok {
-				fmt.Printf("\n")
-				en.Dump(sa)
-			}
-
-			if v, ok := child.(*Value); ok {
-				fmt.Printf("%s", v.String())
-			}
-
-			if v, ok := child.(*Substitution); ok {
-				fmt.Printf("%s", v.Dump(sa))
-			}
-		}
-	}
-
-	if s.StringStructure != nil {
-		fmt.Printf("</%s>\n", s.StringStructure.String())
-	}
+	RenderXML(os.Stdout, s.Element(sa), RenderOptions{
+		Indent: "  ",
+	})
 }
 
 type Children []interface{}
 
-func (s *Children) Decode(d Decoder) {
-	for {
-		if d.PeekUint8() == 4 {
+func (s *Children) Decode(d Decoder, ch *Chunk) {
+	for d.LastError() == nil {
+		switch d.PeekUint8() {
+		case 0x04:
 			d.Uint8()
 			return
-		}
-
-		if d.PeekUint8() == 0x5 {
+		case 0x05, 0x45:
 			v := &Value{}
 			v.Decode(d)
 			*s = append(*s, v)
-		} else if d.PeekUint8() == 0x0d || d.PeekUint8() == 0x0e {
+		case 0x0d, 0x0e:
 			v := &Substitution{}
 			v.Decode(d)
 			*s = append(*s, v)
-		} else if d.PeekUint8() == 0x41 || d.PeekUint8() == 0x01 {
+		case 0x01, 0x41:
 			en := &ElementNode{}
-			en.Decode(d)
+			en.Decode(d, ch)
 			*s = append(*s, en)
-		} else {
-			panic("Unexpected")
+		case 0x07, 0x47:
+			v := &CDataSection{}
+			v.Decode(d)
+			*s = append(*s, v)
+		case 0x08, 0x48:
+			v := &CharRef{}
+			v.Decode(d)
+			*s = append(*s, v)
+		case 0x09, 0x49:
+			v := &EntityRef{}
+			v.Decode(d, ch)
+			*s = append(*s, v)
+		case 0x0a:
+			v := &ProcessingInstruction{}
+			v.Decode(d, ch)
+			*s = append(*s, v)
+		default:
+			unexpected(d)
+		}
+	}
+}
+
+// decodeValue reads the tokens of an attribute value, up to the next
+// attribute or the end of the start element.
+func (s *Children) decodeValue(d Decoder, ch *Chunk) {
+	for d.LastError() == nil {
+		switch d.PeekUint8() {
+		case 0x02, 0x03, 0x06, 0x46:
+			return
+		case 0x05, 0x45:
+			v := &Value{}
+			v.Decode(d)
+			*s = append(*s, v)
+		case 0x0d, 0x0e:
+			v := &Substitution{}
+			v.Decode(d)
+			*s = append(*s, v)
+		case 0x08, 0x48:
+			v := &CharRef{}
+			v.Decode(d)
+			*s = append(*s, v)
+		case 0x09, 0x49:
+			v := &EntityRef{}
+			v.Decode(d, ch)
+			*s = append(*s, v)
+		default:
+			unexpected(d)
 		}
 	}
 }
@@ -298,58 +491,36 @@ type Attributes []*Attribute
 type Attribute struct {
 	StringStructure *StringStructure
 
-	Value        *Value
-	Substitution *Substitution
+	// values, substitutions, character and entity references
+	Children Children
 }
 
 func (s *Attributes) Dump(sa SubstitutionArray) {
-	for _, attribute := range *s {
-		fmt.Printf(" ")
-
-		if attribute.StringStructure != nil {
-			fmt.Printf("%s=", attribute.StringStructure.String())
-		}
-
-		if attribute.Value != nil {
-			fmt.Printf("\"%s\"", attribute.Value.String())
-		} else if attribute.Substitution != nil {
-			fmt.Printf("\"%s\"", attribute.Substitution.Dump(sa))
-		}
+	x := &xmlWriter{
+		w: os.Stdout,
 	}
+
+	x.attrs(s.resolve(sa))
 }
 
-func (s *Attributes) Decode(d Decoder) {
+func (s *Attributes) Decode(d Decoder, ch *Chunk) {
 	_ = d.Uint32() // length
 
-	for {
-		flag := d.Uint8()
-
-		attribute := &Attribute{}
+	for d.LastError() == nil {
+		// 0x46 is followed by more attributes
+		flag := d.PeekUint8()
+		if flag != 0x06 && flag != 0x46 {
+			unexpected(d)
+			return
+		}
 
-		stringPtr := d.Uint32()
-		if (int(stringPtr)) == d.Offset() {
-			ss := &StringStructure{
-				Ptr: stringPtr,
-			}
-			ss.Decode(d)
+		d.Uint8()
 
-			attribute.StringStructure = ss
-		} else {
-			attribute.StringStructure = pointers[stringPtr].(*StringStructure)
+		attribute := &Attribute{
+			StringStructure: ch.decodeString(d),
 		}
 
-		switch d.PeekUint8() {
-		case 0x5:
-			v := &Value{}
-			v.Decode(d)
-			attribute.Value = v
-		case 0xe:
-			fallthrough
-		case 0xd:
-			v := &Substitution{}
-			v.Decode(d)
-			attribute.Substitution = v
-		}
+		attribute.Children.decodeValue(d, ch)
 
 		*s = append(*s, attribute)
 
@@ -359,64 +530,109 @@ func (s *Attributes) Decode(d Decoder) {
 	}
 }
 
+type CDataSection struct {
+	Length uint16
+	Data   []byte
+}
+
+func (s *CDataSection) Decode(d Decoder) {
+	d.Uint8() // 0x07 or 0x47
+
+	s.Length = d.Uint16()
+
+	s.Data = make([]byte, int(s.Length)*2)
+	d.Copy(s.Data)
+}
+
+func (s *CDataSection) String() string {
+	return utf16String(s.Data)
+}
+
+type CharRef struct {
+	Value uint16
+}
+
+func (s *CharRef) Decode(d Decoder) {
+	d.Uint8() // 0x08 or 0x48
+
+	s.Value = d.Uint16()
+}
+
+func (s *CharRef) String() string {
+	return string(rune(s.Value))
+}
+
+type EntityRef struct {
+	StringStructure *StringStructure
+}
+
+func (s *EntityRef) Decode(d Decoder, ch *Chunk) {
+	d.Uint8() // 0x09 or 0x49
+
+	s.StringStructure = ch.decodeString(d)
+}
+
+// Name returns the name of the referenced entity.
+func (s *EntityRef) Name() string {
+	if s.StringStructure == nil {
+		return ""
+	}
+
+	return s.StringStructure.String()
+}
+
+type ProcessingInstruction struct {
+	Target *StringStructure
+
+	Length uint16
+	Data   []byte
+}
+
+func (s *ProcessingInstruction) Decode(d Decoder, ch *Chunk) {
+	d.Uint8() // 0x0a
+
+	s.Target = ch.decodeString(d)
+
+	if !expect(d, 0x0b) {
+		return
+	}
+
+	s.Length = d.Uint16()
+
+	s.Data = make([]byte, int(s.Length)*2)
+	d.Copy(s.Data)
+}
+
 type Substitution struct {
 	Index uint16
 	Type  Type
+
+	// Optional substitutions omit their element or attribute when the
+	// value is empty.
+	Optional bool
 }
 
 func (s *Substitution) Decode(d Decoder) {
-	d.Uint8() // 0xe or 0xd
+	s.Optional = d.Uint8() == 0x0e
 
 	s.Index = d.Uint16()
 	s.Type = Type(d.Uint8())
 }
 
 func (s *Substitution) Dump(sa SubstitutionArray) string {
-	if v, ok := sa[uint32(s.Index)]; !ok {
-		return "Unknown"
-	} else {
-		switch v := v.(type) {
-		case nil:
+	v := sa[uint32(s.Index)]
+	if v, ok := v.(Stream); ok {
+		doc := v.Document()
+		if doc == nil {
 			return ""
-		case bool:
-			if v {
-				return "true"
-			} else {
-				return "false"
-			}
-		case uint64:
-			return fmt.Sprintf("%d", v)
-		case int64:
-			return fmt.Sprintf("%d", v)
-		case uint32:
-			return fmt.Sprintf("%d", v)
-		case int32:
-			return fmt.Sprintf("%d", v)
-		case uint16:
-			return fmt.Sprintf("%d", v)
-		case int16:
-			return fmt.Sprintf("%d", v)
-		case uint8:
-			return fmt.Sprintf("%d", v)
-		case int8:
-			return fmt.Sprintf("%d", v)
-		case string:
-			return fmt.Sprintf("%s", v)
-		case Stream:
-			v.Dump()
-		case Sid:
-			return fmt.Sprintf("%s", v.String())
-		case Guid:
-			return fmt.Sprintf("%s", v.String())
-		case time.Time:
-			return fmt.Sprintf("%s", v.Format(time.RFC3339))
-		default:
-			return fmt.Sprintf("%#v", v)
 		}
 
+		b := bytes.Buffer{}
+		RenderXML(&b, doc, RenderOptions{})
+		return b.String()
 	}
 
-	return ""
+	return formatValue(v)
 }
 
 func (s *Substitution) String() string {
@@ -429,24 +645,44 @@ type Stream struct {
 
 	TemplateDefinition *TemplateDefinition
 	SubstitutionArray  SubstitutionArray
+
+	// ElementNode is set instead of a template for plain fragments
+	ElementNode *ElementNode
 }
 
 func (s *Stream) Dump() {
-	if s.TemplateDefinition != nil {
-		s.TemplateDefinition.Dump(s.SubstitutionArray)
+	if doc := s.Document(); doc != nil {
+		RenderXML(os.Stdout, doc, RenderOptions{
+			Indent: "  ",
+		})
 	}
 }
 
-func (s *Stream) Decode(d Decoder) {
+func (s *Stream) Decode(d Decoder, ch *Chunk) {
 	if d.PeekUint8() == 0xf {
-		Assert(d.Uint8() == 0xf)
-		Assert(d.Uint8() == 0x1)
-		Assert(d.Uint8() == 0x1)
-		Assert(d.Uint8() == 0x0)
+		d.Uint8()
+
+		// major and minor version, flags
+		d.Skip(3)
 	}
 
-	Assert(d.Uint8() == 0xc)
-	Assert(d.Uint8() == 0x1)
+	if token := d.PeekUint8(); token == 0x01 || token == 0x41 {
+		en := &ElementNode{}
+		en.Decode(d, ch)
+		s.ElementNode = en
+
+		if d.LastError() == nil && d.HasBytes(1) && d.PeekUint8() == 0x00 {
+			d.Uint8()
+		}
+
+		return
+	}
+
+	if !expect(d, 0xc) {
+		return
+	}
+
+	d.Uint8() // version
 
 	// https://static1.squarespace.com/static/510d93d8e4b060f86e6fdf2d/t/5328923ee4b0bea727f8aa9b/1395167806310/Windows+7+Audit+Format+v10.pdf
 	s.TemplateID = d.Uint32()
@@ -454,22 +690,29 @@ func (s *Stream) Decode(d Decoder) {
 	s.Ptr = d.Uint32()
 	if (int(s.Ptr)) == d.Offset() {
 		t := &TemplateDefinition{}
-		t.Decode(d)
+		t.Decode(d, ch)
 
 		s.TemplateDefinition = t
 	} else {
-		// s.TemplateDefinition =
+		s.TemplateDefinition = ch.templateAt(d, s.Ptr)
+	}
+
+	if d.LastError() != nil {
+		return
 	}
 
 	t := SubstitutionArray{}
-	t.Decode(d)
+	t.Decode(d, ch)
 	s.SubstitutionArray = t
 }
 
 type SubstitutionArray map[uint32]interface{}
 
-func (s SubstitutionArray) Decode(d Decoder) {
+func (s SubstitutionArray) Decode(d Decoder, ch *Chunk) {
 	count := d.Uint32()
+	if !d.HasBytes(int(count) * 4) {
+		return
+	}
 
 	iis := make([]IndexInfo, count)
 	for i := uint32(0); i < count; i++ {
@@ -479,126 +722,237 @@ func (s SubstitutionArray) Decode(d Decoder) {
 		iis[i] = ii
 	}
 
-	for i := uint32(0); i < count; i++ {
-		length := iis[i].Length
+	// the pointer size of the system that wrote the event, known when the
+	// array has a scalar pointer sized value
+	ptrSize := 0
+	for _, ii := range iis {
+		if (ii.Type == EvtVarTypeSizeT || ii.Type == EvtVarTypeEvtHandle) && (ii.Length == 4 || ii.Length == 8) {
+			ptrSize = int(ii.Length)
+			break
+		}
+	}
 
-		switch iis[i].Type {
-		case EvtVarTypeNull:
+	for i := uint32(0); i < count && d.LastError() == nil; i++ {
+		length := int(iis[i].Length)
+
+		if iis[i].Type == EvtVarTypeNull || length == 0 {
 			s[i] = nil
+
+			d.Skip(length)
 			continue
-		case EvtVarTypeString:
-			buff6 := make([]byte, length)
-			d.Copy(buff6[:])
-
-			utf16 := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
-			if val, err := utf16.Bytes(buff6[:]); err == nil {
-				s[i] = string(val)
-			} else {
-			}
-			continue
-		case EvtVarTypeAnsiString:
-			// fmt.Println("EvtVarTypeAnsiString")
-		case EvtVarTypeSByte:
-			s[i] = d.Uint8()
-			continue
-		case EvtVarTypeByte:
-			s[i] = d.Int8()
-			continue
-		case EvtVarTypeInt16:
-			s[i] = d.Int16()
-			continue
-		case EvtVarTypeUInt16:
-			s[i] = d.Uint16()
-			continue
-		case EvtVarTypeInt32:
-			s[i] = d.Int32()
-			continue
-		case EvtVarTypeUInt32:
-			s[i] = d.Uint32()
-			continue
-		case EvtVarTypeInt64:
-			s[i] = d.Int64()
-			continue
-		case EvtVarTypeUInt64:
-			s[i] = d.Uint64()
-			continue
-		case EvtVarTypeSingle:
-			// fmt.Println("EvtVarTypeSingle")
-		case EvtVarTypeDouble:
-			// fmt.Println("EvtVarTypeDouble")
-		case EvtVarTypeBoolean:
-			// fmt.Println("EvtVarTypeBoolean")
-		case EvtVarTypeBinary:
-			// fmt.Println("EvtVarTypeBinary")
-		case EvtVarTypeGuid:
-			guid := Guid{}
-			d.Copy(guid[:])
-			s[i] = guid
-			continue
-		case EvtVarTypeSizeT:
-			// fmt.Println("EvtVarTypeSizeT")
-		case EvtVarTypeFileTime:
-			lowDateTime := int64(d.Uint32())
-			highDateTime := int64(d.Uint32())
-			nsec := int64(highDateTime)<<32 + int64(lowDateTime)
-			nsec -= 116444736000000000
-			nsec *= 100
-			s[i] = time.Unix(0, nsec)
-			continue
-		case EvtVarTypeSysTime:
-			lowDateTime := int64(d.Uint32())
-			highDateTime := int64(d.Uint32())
-			nsec := int64(highDateTime)<<32 + int64(lowDateTime)
-			nsec -= 116444736000000000
-			nsec *= 100
-			s[i] = time.Unix(0, nsec)
-		case EvtVarTypeSid:
-			sid := Sid{}
-			sid.Revision = d.Uint8()
-			sid.SubAuthorityCount = d.Uint8()
-			d.Copy(sid.IdentifierAuthority[:])
+		}
 
-			sid.SubAuthority = make([]uint32, sid.SubAuthorityCount)
-			for i := uint8(0); i < sid.SubAuthorityCount; i++ {
-				sid.SubAuthority[i] = d.Uint32()
-			}
+		start := d.Offset()
 
-			s[i] = sid
-			continue
-		case EvtVarTypeHexInt32:
-			s[i] = fmt.Sprintf("0x%X", d.Uint32())
-			continue
-		case EvtVarTypeHexInt64:
-			s[i] = fmt.Sprintf("0x%X", d.Uint64())
-			continue
-		case EvtVarTypeEvtHandle:
-			data := make([]byte, iis[i].Length)
-			d.Copy(data)
+		if iis[i].Type&EvtVarTypeArray != 0 {
+			s[i] = decodeArray(d, iis[i].Type&^EvtVarTypeArray, length, ptrSize, ch)
+		} else {
+			s[i] = decodeValue(d, iis[i].Type, length, ch)
+		}
 
-			s[i] = fmt.Sprintf("EvtVarTypeEvtHandle  %x", data)
-			continue
-		case BinaryXmlStream:
-			startOffset := d.Offset()
+		// realign, values of unknown types are skipped
+		d.Seek(start + length)
+	}
+}
+
+// decodeValue decodes a single value of type t, stored in length bytes.
+func decodeValue(d Decoder, t Type, length int, ch *Chunk) interface{} {
+	switch t {
+	case EvtVarTypeString:
+		buff6 := make([]byte, length)
+		d.Copy(buff6[:])
+
+		// strings are stored with or without their terminator
+		return strings.TrimRight(utf16String(buff6), "\x00")
+	case EvtVarTypeAnsiString:
+		buff := make([]byte, length)
+		d.Copy(buff)
+
+		return strings.TrimRight(string(buff), "\x00")
+	case EvtVarTypeSByte:
+		return d.Int8()
+	case EvtVarTypeByte:
+		return d.Uint8()
+	case EvtVarTypeInt16:
+		return d.Int16()
+	case EvtVarTypeUInt16:
+		return d.Uint16()
+	case EvtVarTypeInt32:
+		return d.Int32()
+	case EvtVarTypeUInt32:
+		return d.Uint32()
+	case EvtVarTypeInt64:
+		return d.Int64()
+	case EvtVarTypeUInt64:
+		return d.Uint64()
+	case EvtVarTypeSingle:
+		return d.IEEE754_Float32()
+	case EvtVarTypeDouble:
+		return d.IEEE754_Float64()
+	case EvtVarTypeBoolean:
+		return d.Uint32() != 0
+	case EvtVarTypeBinary:
+		data := make([]byte, length)
+		d.Copy(data)
+		return data
+	case EvtVarTypeGuid:
+		guid := Guid{}
+		d.Copy(guid[:])
+		return guid
+	case EvtVarTypeSizeT:
+		// the size of the pointer of the system that wrote the event
+		if length == 4 {
+			return SizeT(d.Uint32())
+		}
+
+		return SizeT(d.Uint64())
+	case EvtVarTypeFileTime:
+		return FileTime(d.Uint64())
+	case EvtVarTypeSysTime:
+		return decodeSystemTime(d)
+	case EvtVarTypeSid:
+		sid := Sid{}
+		sid.Decode(d)
+		return sid
+	case EvtVarTypeHexInt32:
+		return HexInt32(d.Uint32())
+	case EvtVarTypeHexInt64:
+		return HexInt64(d.Uint64())
+	case EvtVarTypeEvtHandle:
+		if length == 4 {
+			return EvtHandle(d.Uint32())
+		}
 
+		return EvtHandle(d.Uint64())
+	case BinaryXmlStream:
+		stream := Stream{}
+		stream.Decode(d, ch)
+		return stream
+	case EvtVarTypeEvtXml:
+		// binary XML is decoded like a nested stream, otherwise the value is
+		// an XML string
+		switch d.PeekUint8() {
+		case 0x0f, 0x0c, 0x01, 0x41:
 			stream := Stream{}
-			stream.Decode(d)
+			stream.Decode(d, ch)
+			return stream
+		}
 
-			d.Seek(startOffset + int(iis[i].Length))
+		buff := make([]byte, length)
+		d.Copy(buff)
 
-			s[i] = stream
-			continue
-		case EvtVarTypeEvtXml:
-			s[i] = "EvtVarTypeEvtXML"
+		return strings.TrimRight(utf16String(buff), "\x00")
+	}
+
+	return nil
+}
+
+// decodeSystemTime decodes a 16 byte SYSTEMTIME structure as UTC.
+func decodeSystemTime(d Decoder) time.Time {
+	year := int(d.Uint16())
+	month := time.Month(d.Uint16())
+	d.Uint16() // day of week
+	day := int(d.Uint16())
+	hour := int(d.Uint16())
+	minute := int(d.Uint16())
+	second := int(d.Uint16())
+	msec := int(d.Uint16())
+
+	if year == 0 && month == 0 && day == 0 {
+		return time.Time{}
+	}
+
+	return time.Date(year, month, day, hour, minute, second, msec*int(time.Millisecond), time.UTC)
+}
+
+// decodeArray decodes the values of an array of type t into a typed slice.
+// Strings are terminated, other values have a fixed size. SizeT items have
+// the pointer size ptrSize, or 0 when it is not known.
+func decodeArray(d Decoder, t Type, length int, ptrSize int, ch *Chunk) interface{} {
+	end := d.Offset() + length
+
+	switch t {
+	case EvtVarTypeString, EvtVarTypeAnsiString:
+		buff := make([]byte, length)
+		d.Copy(buff)
+
+		value := string(buff)
+		if t == EvtVarTypeString {
+			value = utf16String(buff)
 		}
 
-		/*
-			if d.Offset() != startOffset+int(iis[i].Length) {
-				panic("Not equal")
-			}
-		*/
+		values := strings.Split(value, "\x00")
+		if values[len(values)-1] == "" {
+			values = values[:len(values)-1]
+		}
+
+		return values
+	case EvtVarTypeSid:
+		values := []Sid{}
+		for d.Offset() < end && d.LastError() == nil {
+			sid := Sid{}
+			sid.Decode(d)
+			values = append(values, sid)
+		}
+
+		return values
+	case EvtVarTypeByte:
+		values := make(ByteArray, length)
+		d.Copy(values)
+		return values
+	}
+
+	size, ok := typeSizes[t]
+	if t == EvtVarTypeSizeT {
+		// the item count is not stored, without a known pointer size
+		// lengths that are a multiple of 8 are taken as the pointers of a
+		// 64 bit system
+		size, ok = 8, true
+		if ptrSize == 4 || length%8 != 0 {
+			size = 4
+		}
+	}
 
-		d.Skip(int(iis[i].Length))
+	if !ok {
+		return nil
 	}
+
+	var values reflect.Value
+
+	for d.Offset()+size <= end && d.LastError() == nil {
+		v := reflect.ValueOf(decodeValue(d, t, size, ch))
+		if !values.IsValid() {
+			values = reflect.MakeSlice(reflect.SliceOf(v.Type()), 0, length/size)
+		}
+
+		values = reflect.Append(values, v)
+	}
+
+	if !values.IsValid() {
+		return nil
+	}
+
+	return values.Interface()
+}
+
+// typeSizes holds the size of the fixed size types that can be used in
+// arrays.
+var typeSizes = map[Type]int{
+	EvtVarTypeSByte:    1,
+	EvtVarTypeInt16:    2,
+	EvtVarTypeUInt16:   2,
+	EvtVarTypeInt32:    4,
+	EvtVarTypeUInt32:   4,
+	EvtVarTypeInt64:    8,
+	EvtVarTypeUInt64:   8,
+	EvtVarTypeSingle:   4,
+	EvtVarTypeDouble:   8,
+	EvtVarTypeBoolean:  4,
+	EvtVarTypeGuid:     16,
+	EvtVarTypeFileTime: 8,
+	EvtVarTypeSysTime:  16,
+	EvtVarTypeHexInt32: 4,
+	EvtVarTypeHexInt64: 8,
 }
 
 type IndexInfo struct {
@@ -641,9 +995,16 @@ const (
 	EvtVarTypeEvtHandle       = 0x20
 	BinaryXmlStream           = 0x21
 	EvtVarTypeEvtXml          = 0x23
+
+	// EvtVarTypeArray is set on the type of array values
+	EvtVarTypeArray Type = 0x80
 )
 
 func (t Type) String() string {
+	if t&EvtVarTypeArray != 0 && t != EvtVarTypeArray {
+		return (t &^ EvtVarTypeArray).String() + "Array"
+	}
+
 	switch t {
 	case EvtVarTypeNull:
 		return "EvtVarTypeNull"
@@ -707,11 +1068,18 @@ type Value struct {
 }
 
 func (s *Value) Decode(d Decoder) {
-	Assert(d.Uint8() == 0x5)
+	// 0x45 is followed by more data
+	if token := d.PeekUint8(); token != 0x05 && token != 0x45 {
+		unexpected(d)
+		return
+	}
+
+	d.Uint8()
+
 	s.Type = d.Uint8()
 	s.Length = d.Uint16()
 
-	s.Data = make([]byte, s.Length*2)
+	s.Data = make([]byte, int(s.Length)*2)
 	d.Copy(s.Data[:])
 }
 
@@ -724,8 +1092,6 @@ func (s *Value) String() string {
 	}
 }
 
-var pointers map[uint32]interface{} = map[uint32]interface{}{}
-
 type StringStructure struct {
 	Ptr      uint32
 	NextPtr  uint32
@@ -743,44 +1109,88 @@ func (s *StringStructure) String() string {
 	}
 }
 
-func (s *StringStructure) Decode(d Decoder) {
+func (s *StringStructure) Decode(d Decoder, ch *Chunk) {
 	s.NextPtr = d.Uint32()
 	s.Checksum = d.Uint16()
 
 	s.Count = d.Uint16()
 
-	s.Data = make([]byte, s.Count*2+2)
+	s.Data = make([]byte, int(s.Count)*2+2)
 	d.Copy(s.Data)
 
-	pointers[s.Ptr] = s
+	if d.LastError() != nil {
+		return
+	}
+
+	ch.Strings[s.Ptr] = s
 }
 
-func Assert(b bool) {
-	if b {
-		return
+// templateAt returns the template defined at chunk offset ptr, decoding it
+// when the record that defined it has not been read.
+func (ch *Chunk) templateAt(d Decoder, ptr uint32) *TemplateDefinition {
+	if t, ok := ch.lookupTemplate(ptr); ok {
+		return t
 	}
 
-	panic("expr")
+	prev := d.Seek(int(ptr))
+	defer d.Seek(prev)
+
+	t := &TemplateDefinition{}
+	t.Decode(d, ch)
+	return t
 }
 
-type parser struct {
+// decodeString reads a name pointer, the name itself follows inline when it
+// is the first occurrence in the chunk.
+func (ch *Chunk) decodeString(d Decoder) *StringStructure {
+	ptr := d.Uint32()
+	if d.LastError() != nil {
+		return nil
+	}
+
+	if int(ptr) == d.Offset() {
+		ss := &StringStructure{
+			Ptr: ptr,
+		}
+		ss.Decode(d, ch)
+		return ss
+	}
+
+	return ch.stringAt(d, ptr)
 }
 
-func Parse(data []byte) *parser {
-	p := parser{}
+// stringAt returns the name at chunk offset ptr, decoding it when it has not
+// been seen yet.
+func (ch *Chunk) stringAt(d Decoder, ptr uint32) *StringStructure {
+	if ss, ok := ch.lookupString(ptr); ok {
+		return ss
+	}
+
+	prev := d.Seek(int(ptr))
+	defer d.Seek(prev)
 
-	d := NewDefaultDecoder(data, binary.LittleEndian)
+	ss := &StringStructure{
+		Ptr: ptr,
+	}
+	ss.Decode(d, ch)
+	return ss
+}
 
-	h := Header{}
-	h.Decode(d)
+type parser struct {
+}
 
-	for i := 0; i < int(h.Count); i++ {
-		subDecoder := d.NewDecoder()
+// Parse decodes data as an evtx file and dumps every record to stdout.
+func Parse(data []byte) *parser {
+	p := parser{}
 
-		ch := Chunk{}
-		ch.Decode(subDecoder)
+	f, err := Open(bytes.NewReader(data))
+	if err != nil {
+		return &p
+	}
 
-		d.Skip(0x10000)
+	records := f.Records()
+	for records.Next() {
+		records.Record().Stream.Dump()
 	}
 
 	return &p
@@ -0,0 +1,398 @@
+package evtxparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	HeaderSize = 0x1000
+	ChunkSize  = 0x10000
+)
+
+// File is an evtx file opened for random access.
+type File struct {
+	Header Header
+
+	r      io.ReaderAt
+	chunks int
+}
+
+// Open reads the file header from r.
+func Open(r io.ReaderAt) (*File, error) {
+	buff := make([]byte, HeaderSize)
+	if err := readAt(r, buff, 0, -1); err != nil {
+		return nil, err
+	}
+
+	f := &File{
+		r: r,
+	}
+
+	d := NewDefaultDecoder(buff, binary.LittleEndian)
+	f.Header.Decode(d)
+
+	if err := d.LastError(); err != nil {
+		return nil, annotate(err, d, -1, 0, 0)
+	}
+
+	f.chunks = int(f.Header.Count)
+
+	// like the Windows recovery path, valid chunks following the ones in the
+	// header of a dirty log are part of it, up to the first invalid region
+	if f.Header.IsDirty() {
+		regions, err := f.ScanChunks()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := f.chunks; i < len(regions) && regions[i].Valid(); i++ {
+			f.chunks = i + 1
+		}
+	}
+
+	return f, nil
+}
+
+func readAt(r io.ReaderAt, buff []byte, offset int64, chunk int) error {
+	n, err := r.ReadAt(buff, offset)
+	if n == len(buff) {
+		return nil
+	}
+
+	if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated{
+			Chunk:  chunk,
+			Offset: offset,
+			Got:    n,
+			Want:   len(buff),
+		}
+	}
+
+	return err
+}
+
+// ChunkCount returns the number of chunks of the file. This is the chunk count
+// of the header, extended with the valid chunks that directly follow it when
+// the log is dirty. The extension stops at the first region that is not a
+// valid chunk, valid chunks after it are only reported by ScanChunks and are
+// not iterated.
+func (f *File) ChunkCount() int {
+	return f.chunks
+}
+
+// ChunkOffset returns the absolute file offset of chunk i.
+func (f *File) ChunkOffset(i int) int64 {
+	return HeaderSize + int64(i)*ChunkSize
+}
+
+// Chunk reads and decodes the header, string table and template table of
+// chunk i.
+func (f *File) Chunk(i int) (*Chunk, error) {
+	buff, err := f.readChunk(i)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeChunk(buff, i, f.ChunkOffset(i))
+}
+
+// decodeChunk decodes the chunk data found at offset.
+func decodeChunk(data []byte, index int, offset int64) (*Chunk, error) {
+	ch := &Chunk{
+		Index:  index,
+		Offset: offset,
+	}
+
+	d := NewDefaultDecoder(data, binary.LittleEndian)
+	ch.Decode(d)
+
+	if err := d.LastError(); err != nil {
+		return nil, annotate(err, d, index, offset, 0)
+	}
+
+	return ch, nil
+}
+
+func (f *File) readChunk(i int) ([]byte, error) {
+	buff := make([]byte, ChunkSize)
+	if err := readAt(f.r, buff, f.ChunkOffset(i), i); err != nil {
+		return nil, err
+	}
+
+	return buff, nil
+}
+
+// ChunkRegion is a chunk-sized region of the file found by ScanChunks.
+type ChunkRegion struct {
+	Index  int
+	Offset int64
+
+	// Accounted is set when the region is within the chunk count of the
+	// file header.
+	Accounted bool
+
+	// Err is set when the region does not hold a chunk header with a valid
+	// magic and checksum.
+	Err error
+}
+
+func (r ChunkRegion) Valid() bool {
+	return r.Err == nil
+}
+
+// ScanChunks validates every chunk-sized region up to the end of the file,
+// regardless of the chunk count of the header. Regions that are valid but
+// not Accounted are chunks the header does not know about.
+func (f *File) ScanChunks() ([]ChunkRegion, error) {
+	regions := []ChunkRegion{}
+
+	for i := 0; ; i++ {
+		region := ChunkRegion{
+			Index:     i,
+			Offset:    f.ChunkOffset(i),
+			Accounted: i < int(f.Header.Count),
+		}
+
+		data, err := f.readChunk(i)
+		if e, ok := err.(ErrTruncated); ok {
+			if e.Got == 0 {
+				break
+			}
+
+			// a partial chunk at the end of the file
+			region.Err = err
+			regions = append(regions, region)
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		region.Err = checkChunk(data, i, region.Offset)
+		regions = append(regions, region)
+	}
+
+	return regions, nil
+}
+
+// checkChunk validates the magic and header checksum of chunk data.
+func checkChunk(data []byte, index int, offset int64) error {
+	if string(data[:len(MagicElfChunk)]) != string(MagicElfChunk) {
+		return ErrBadMagic{
+			Chunk:  index,
+			Offset: offset,
+			Magic:  append([]byte{}, data[:len(MagicElfChunk)]...),
+		}
+	}
+
+	if header, _ := verifyChunk(data); !header.Valid() {
+		return ErrChecksum{
+			Chunk:    index,
+			Offset:   offset,
+			Checksum: header,
+		}
+	}
+
+	return nil
+}
+
+// Record is a decoded event record together with its location in the file.
+type Record struct {
+	AuditRecord
+
+	Chunk  int
+	Offset int64
+
+	// Recovered is set for records found in the slack of the preceding
+	// record, which was grown to merge and hide them.
+	Recovered bool
+}
+
+// Record decodes the record at the chunk relative offset.
+func (ch *Chunk) Record(offset int) (*Record, error) {
+	d := NewDefaultDecoder(ch.data, binary.LittleEndian)
+	d.Seek(offset)
+
+	r := &Record{
+		Chunk:  ch.Index,
+		Offset: ch.Offset + int64(offset),
+	}
+
+	r.AuditRecord.Decode(d, ch.scratch())
+
+	if err := d.LastError(); err != nil {
+		return nil, annotate(err, d, ch.Index, ch.Offset, r.RecordID)
+	}
+
+	return r, nil
+}
+
+// recoverMerged returns the records hidden in the slack of r. Tools that
+// delete a record by growing the preceding one rewrite the trailing size copy
+// of the deleted record to the combined size.
+func (ch *Chunk) recoverMerged(r *Record) []*Record {
+	records := []*Record{}
+
+	if r.Slack < minRecordSize {
+		return records
+	}
+
+	start := int(r.Offset - ch.Offset)
+	end := start + int(r.Length)
+
+	p := end - 4 - int(r.Slack)
+	for p+minRecordSize <= end {
+		i := bytes.Index(ch.data[p:end], MagicAuditRecord)
+		if i < 0 {
+			break
+		}
+
+		p += i
+
+		length := int(binary.LittleEndian.Uint32(ch.data[p+4:]))
+		if length < minRecordSize || p+length > end {
+			p++
+			continue
+		}
+
+		trailer := int(binary.LittleEndian.Uint32(ch.data[p+length-4:]))
+		if trailer != length && trailer != p+length-start {
+			p++
+			continue
+		}
+
+		// decode a copy with the original size copy restored
+		data := append([]byte{}, ch.data[:p+length]...)
+		binary.LittleEndian.PutUint32(data[p+length-4:], uint32(length))
+
+		// names and templates from slack never end up in the chunk
+		c := ch.scratch()
+		c.data = data
+
+		hidden, err := c.Record(p)
+		if err != nil {
+			p++
+			continue
+		}
+
+		hidden.Recovered = true
+		records = append(records, hidden)
+
+		p += length
+	}
+
+	return records
+}
+
+// Records returns an iterator over all records of the file.
+func (f *File) Records() *RecordIterator {
+	return &RecordIterator{
+		f: f,
+	}
+}
+
+// Records returns an iterator over the records of the chunk only.
+func (ch *Chunk) Records() *RecordIterator {
+	return &RecordIterator{
+		ch:     ch,
+		offset: 512,
+		end:    ch.freeOffset(),
+	}
+}
+
+// freeOffset returns the chunk offset of the free space following the last
+// record.
+func (ch *Chunk) freeOffset() int {
+	free := int(ch.Header.PtrToNext)
+	if free < 512 {
+		return 512
+	}
+
+	if free > len(ch.data) {
+		return len(ch.data)
+	}
+
+	return free
+}
+
+// RecordIterator walks the records of a file chunk by chunk. A chunk or
+// record that cannot be decoded does not end the iteration, the error is
+// kept and the walk continues with the next chunk.
+type RecordIterator struct {
+	f *File
+
+	chunk  int
+	ch     *Chunk
+	offset int
+	end    int
+
+	// records recovered from the slack of the current record
+	recovered []*Record
+
+	record *Record
+	errs   []error
+}
+
+// Next advances to the next record, it returns false at the end of the
+// file. When a record cannot be decoded the following records of its chunk
+// cannot be located and are skipped.
+func (it *RecordIterator) Next() bool {
+	if len(it.recovered) > 0 {
+		it.record, it.recovered = it.recovered[0], it.recovered[1:]
+		return true
+	}
+
+	for {
+		// records end at the free space of the chunk
+		for it.ch == nil || it.offset+minRecordSize > it.end {
+			if it.f == nil || it.chunk >= it.f.ChunkCount() {
+				return false
+			}
+
+			ch, err := it.f.Chunk(it.chunk)
+			it.chunk++
+
+			if err != nil {
+				it.errs = append(it.errs, err)
+				continue
+			}
+
+			it.ch = ch
+			it.offset = 512
+			it.end = ch.freeOffset()
+		}
+
+		r, err := it.ch.Record(it.offset)
+		if err != nil {
+			it.errs = append(it.errs, err)
+			it.offset = it.end
+			continue
+		}
+
+		it.offset += int(r.Length)
+		it.record = r
+		it.recovered = it.ch.recoverMerged(r)
+		return true
+	}
+}
+
+// Record returns the current record.
+func (it *RecordIterator) Record() *Record {
+	return it.record
+}
+
+// Err returns the first error encountered during iteration.
+func (it *RecordIterator) Err() error {
+	if len(it.errs) == 0 {
+		return nil
+	}
+
+	return it.errs[0]
+}
+
+// Errs returns all errors encountered during iteration, at most one per
+// chunk.
+func (it *RecordIterator) Errs() []error {
+	return it.errs
+}
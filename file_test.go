@@ -0,0 +1,240 @@
+package evtxparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func openFixture(t *testing.T, data []byte) *File {
+	f, err := Open(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestChunkRecordConcurrent(t *testing.T) {
+	f := openFixture(t, readFixture(t, "records.evtx"))
+
+	ch, err := f.Chunk(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offsets := []int{}
+
+	it := ch.Records()
+	for it.Next() {
+		offsets = append(offsets, int(it.Record().Offset-ch.Offset))
+	}
+
+	if len(offsets) == 0 {
+		t.Fatalf("no records: %v", it.Err())
+	}
+
+	strings, templates := len(ch.Strings), len(ch.Templates)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for _, offset := range offsets {
+				r, err := ch.Record(offset)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				r.Stream.Document()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(ch.Strings) != strings || len(ch.Templates) != templates {
+		t.Errorf("decoding records modified the chunk: %d strings, %d templates, was %d, %d", len(ch.Strings), len(ch.Templates), strings, templates)
+	}
+}
+
+func TestRecordsContinueAfterError(t *testing.T) {
+	data := readFixture(t, "records.evtx")
+	chunk := data[HeaderSize : HeaderSize+ChunkSize]
+
+	// three copies of the chunk, the second record of the first is broken
+	data = append(append(append([]byte{}, data...), chunk...), chunk...)
+	binary.LittleEndian.PutUint16(data[42:], 3)
+	binary.LittleEndian.PutUint32(data[124:], crc32.ChecksumIEEE(data[:120]))
+
+	first := HeaderSize + 512
+	second := first + int(binary.LittleEndian.Uint32(data[first+4:]))
+	length := binary.LittleEndian.Uint32(data[second+4:])
+	binary.LittleEndian.PutUint32(data[second+4:], length+8)
+
+	f := openFixture(t, data)
+
+	count := 0
+
+	it := f.Records()
+	for it.Next() {
+		count++
+	}
+
+	if count != 23 {
+		t.Errorf("got %d records, want 23", count)
+	}
+
+	if len(it.Errs()) != 1 {
+		t.Fatalf("got errors %v, want one", it.Errs())
+	}
+
+	if _, ok := it.Err().(ErrRecordSize); !ok {
+		t.Errorf("got %#v, want ErrRecordSize", it.Err())
+	}
+}
+
+// TestRecoverMerged reads a chunk in which records 4 and then 5 were hidden
+// by growing record 3 over them, the size copy at the end of each hidden
+// record holds the size record 3 had after the merge.
+func TestRecoverMerged(t *testing.T) {
+	f := openFixture(t, readFixture(t, "merged.evtx"))
+
+	v, err := f.Verify()
+	if err != nil || !v.Valid() {
+		t.Fatalf("fixture checksums: %+v, %v", v, err)
+	}
+
+	ch, err := f.Chunk(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strings, templates := len(ch.Strings), len(ch.Templates)
+
+	ids := []uint64{}
+	recovered := []uint64{}
+
+	it := ch.Records()
+	for it.Next() {
+		r := it.Record()
+		ids = append(ids, r.RecordID)
+
+		if r.Recovered {
+			recovered = append(recovered, r.RecordID)
+		}
+
+		if r.RecordID == 3 && r.Slack == 0 {
+			t.Errorf("merged record 3 has no slack")
+		}
+
+		if doc := r.Stream.Document(); doc == nil {
+			t.Errorf("record %d has no document", r.RecordID)
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}; !equalIDs(ids, want) {
+		t.Errorf("got records %v, want %v", ids, want)
+	}
+
+	if want := []uint64{4, 5}; !equalIDs(recovered, want) {
+		t.Errorf("recovered records %v, want %v", recovered, want)
+	}
+
+	if len(ch.Strings) != strings || len(ch.Templates) != templates {
+		t.Errorf("recovering records modified the chunk")
+	}
+}
+
+func TestDirtyLog(t *testing.T) {
+	data := readFixture(t, "records.evtx")
+	chunk := data[HeaderSize : HeaderSize+ChunkSize]
+
+	// the header counts one chunk, followed by a valid chunk, a zeroed
+	// region and another valid chunk
+	data = append(append([]byte{}, data...), chunk...)
+	data = append(data, make([]byte, ChunkSize)...)
+	data = append(data, chunk...)
+
+	tests := []struct {
+		flags  uint32
+		chunks int
+	}{
+		{0, 1},
+		{HeaderFlagDirty, 2},
+		{HeaderFlagDirty | HeaderFlagFull, 2},
+	}
+
+	for _, test := range tests {
+		binary.LittleEndian.PutUint32(data[120:], test.flags)
+		binary.LittleEndian.PutUint32(data[124:], crc32.ChecksumIEEE(data[:120]))
+
+		f := openFixture(t, data)
+
+		if f.Header.IsDirty() != (test.flags&HeaderFlagDirty != 0) || f.Header.IsFull() != (test.flags&HeaderFlagFull != 0) {
+			t.Errorf("flags %#x: dirty %v, full %v", test.flags, f.Header.IsDirty(), f.Header.IsFull())
+		}
+
+		if n := f.ChunkCount(); n != test.chunks {
+			t.Errorf("flags %#x: %d chunks, want %d", test.flags, n, test.chunks)
+		}
+
+		count := 0
+
+		it := f.Records()
+		for it.Next() {
+			count++
+		}
+
+		if want := 11 * test.chunks; count != want || it.Err() != nil {
+			t.Errorf("flags %#x: %d records, %v, want %d", test.flags, count, it.Err(), want)
+		}
+
+		regions, err := f.ScanChunks()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(regions) != 4 {
+			t.Fatalf("flags %#x: %d regions, want 4", test.flags, len(regions))
+		}
+
+		for i, region := range regions {
+			if region.Index != i || region.Offset != f.ChunkOffset(i) {
+				t.Errorf("region %d at index %d offset %#x", i, region.Index, region.Offset)
+			}
+
+			if region.Accounted != (i == 0) {
+				t.Errorf("region %d accounted %v", i, region.Accounted)
+			}
+
+			if region.Valid() != (i != 2) {
+				t.Errorf("region %d valid %v: %v", i, region.Valid(), region.Err)
+			}
+		}
+
+		if _, ok := regions[2].Err.(ErrBadMagic); !ok {
+			t.Errorf("zeroed region: %#v, want ErrBadMagic", regions[2].Err)
+		}
+	}
+}
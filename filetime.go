@@ -0,0 +1,58 @@
+package evtxparser
+
+import (
+	"fmt"
+	"time"
+)
+
+// WindowsTimeFormat is the layout Windows uses for event times.
+const WindowsTimeFormat = "2006-01-02T15:04:05.0000000Z07:00"
+
+// FileTimeNever is the largest valid FILETIME, used for times that never
+// occur.
+const FileTimeNever FileTime = 0x7fffffffffffffff
+
+// FileTime is a Windows FILETIME, the number of 100 nanosecond intervals
+// since January 1, 1601 UTC. The raw value is kept so no precision is lost.
+//
+// A zero FILETIME, which is not set, and a never FILETIME are not a point in
+// time. Time returns the zero time.Time and String an empty string for both,
+// IsZero and IsNever tell them apart.
+type FileTime uint64
+
+// seconds between January 1, 1601 and January 1, 1970
+const fileTimeUnixOffset = 11644473600
+
+// IsZero reports whether the FILETIME is not set.
+func (ft FileTime) IsZero() bool {
+	return ft == 0
+}
+
+// IsNever reports whether the FILETIME is the never sentinel or is beyond
+// the range Windows converts.
+func (ft FileTime) IsNever() bool {
+	return ft >= FileTimeNever
+}
+
+// Time returns the FILETIME as UTC time, or the zero time when it is zero or
+// never.
+func (ft FileTime) Time() time.Time {
+	if ft.IsZero() || ft.IsNever() {
+		return time.Time{}
+	}
+
+	sec := int64(uint64(ft)/1e7) - fileTimeUnixOffset
+	nsec := int64(uint64(ft)%1e7) * 100
+	return time.Unix(sec, nsec).UTC()
+}
+
+// String formats the FILETIME like Windows, with 100 nanosecond precision.
+// It returns an empty string when the FILETIME is zero or never.
+func (ft FileTime) String() string {
+	if ft.IsZero() || ft.IsNever() {
+		return ""
+	}
+
+	sec := int64(uint64(ft)/1e7) - fileTimeUnixOffset
+	return fmt.Sprintf("%s.%07dZ", time.Unix(sec, 0).UTC().Format("2006-01-02T15:04:05"), uint64(ft)%1e7)
+}
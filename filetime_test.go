@@ -0,0 +1,37 @@
+package evtxparser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileTime(t *testing.T) {
+	tests := []struct {
+		ft   FileTime
+		time time.Time
+		s    string
+	}{
+		{0, time.Time{}, ""},
+		{1, time.Date(1601, 1, 1, 0, 0, 0, 100, time.UTC), "1601-01-01T00:00:00.0000001Z"},
+		{116444736000000000, time.Unix(0, 0).UTC(), "1970-01-01T00:00:00.0000000Z"},
+		{131183208010000001, time.Date(2016, 9, 14, 10, 0, 1, 100, time.UTC), "2016-09-14T10:00:01.0000001Z"},
+		{FileTimeNever - 1, time.Date(30828, 9, 14, 2, 48, 5, 477580600, time.UTC), "30828-09-14T02:48:05.4775806Z"},
+		{FileTimeNever, time.Time{}, ""},
+		{0xffffffffffffffff, time.Time{}, ""},
+	}
+
+	for _, test := range tests {
+		if v := test.ft.Time(); !v.Equal(test.time) {
+			t.Errorf("FileTime(%#x).Time() = %s, want %s", uint64(test.ft), v, test.time)
+		}
+
+		if s := test.ft.String(); s != test.s {
+			t.Errorf("FileTime(%#x).String() = %q, want %q", uint64(test.ft), s, test.s)
+		}
+
+		// both methods agree on what is not a time
+		if test.ft.Time().IsZero() != (test.ft.String() == "") {
+			t.Errorf("FileTime(%#x) has time %s and string %q", uint64(test.ft), test.ft.Time(), test.ft.String())
+		}
+	}
+}
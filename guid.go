@@ -2,11 +2,68 @@ package evtxparser
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 type Guid [16]byte
 
+// String returns the guid in registry format, {XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX}.
 func (g Guid) String() string {
-	return fmt.Sprintf("{%08X-%04X-%04X-%04X-%X}", binary.LittleEndian.Uint32(g[0:4]), binary.LittleEndian.Uint16(g[4:6]), binary.LittleEndian.Uint16(g[6:8]), g[8:10], g[10:])
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		binary.LittleEndian.Uint32(g[0:4]), binary.LittleEndian.Uint16(g[4:6]), binary.LittleEndian.Uint16(g[6:8]),
+		g[8], g[9], g[10], g[11], g[12], g[13], g[14], g[15])
+}
+
+// IsZero reports whether g is the nil guid.
+func (g Guid) IsZero() bool {
+	return g == Guid{}
+}
+
+func (g Guid) Equal(other Guid) bool {
+	return g == other
+}
+
+func (g Guid) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+func (g *Guid) UnmarshalText(text []byte) error {
+	v, err := ParseGuid(string(text))
+	if err != nil {
+		return err
+	}
+
+	*g = v
+	return nil
+}
+
+// ParseGuid parses a guid in registry format, the braces are optional and
+// hex digits are case insensitive.
+func ParseGuid(s string) (Guid, error) {
+	g := Guid{}
+
+	v := s
+	if strings.HasPrefix(v, "{") && strings.HasSuffix(v, "}") {
+		v = v[1 : len(v)-1]
+	}
+
+	parts := strings.Split(v, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return g, fmt.Errorf("invalid guid %q", s)
+	}
+
+	data, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return g, fmt.Errorf("invalid guid %q", s)
+	}
+
+	// the first three groups are stored little endian
+	binary.LittleEndian.PutUint32(g[0:4], binary.BigEndian.Uint32(data[0:4]))
+	binary.LittleEndian.PutUint16(g[4:6], binary.BigEndian.Uint16(data[4:6]))
+	binary.LittleEndian.PutUint16(g[6:8], binary.BigEndian.Uint16(data[6:8]))
+	copy(g[8:], data[8:])
+
+	return g, nil
 }
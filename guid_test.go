@@ -0,0 +1,91 @@
+package evtxparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+var guidTests = []struct {
+	data []byte
+	s    string
+}{
+	// Microsoft-Windows-Security-Auditing
+	{[]byte{0x25, 0x96, 0x84, 0x54, 0x78, 0x54, 0x94, 0x49, 0xa5, 0xba, 0x3e, 0x3b, 0x03, 0x28, 0xc3, 0x0d}, "{54849625-5478-4994-A5BA-3E3B0328C30D}"},
+	// Microsoft-Windows-Eventlog
+	{[]byte{0xd8, 0xdd, 0x65, 0xfc, 0xef, 0xd6, 0x62, 0x49, 0x83, 0xd5, 0x6e, 0x5c, 0xfe, 0x9c, 0xe1, 0x48}, "{FC65DDD8-D6EF-4962-83D5-6E5CFE9CE148}"},
+	{make([]byte, 16), "{00000000-0000-0000-0000-000000000000}"},
+}
+
+func TestGuid(t *testing.T) {
+	for _, test := range guidTests {
+		g := Guid{}
+		copy(g[:], test.data)
+
+		if s := g.String(); s != test.s {
+			t.Errorf("String() of % x = %s, want %s", test.data, s, test.s)
+		}
+
+		text, err := g.MarshalText()
+		if err != nil || string(text) != test.s {
+			t.Errorf("MarshalText() of % x = %s, %v, want %s", test.data, text, err, test.s)
+		}
+
+		v := Guid{}
+		if err := v.UnmarshalText([]byte(test.s)); err != nil || !v.Equal(g) {
+			t.Errorf("UnmarshalText(%s) = % x, %v, want % x", test.s, v[:], err, test.data)
+		}
+	}
+}
+
+func TestParseGuid(t *testing.T) {
+	want := "{54849625-5478-4994-A5BA-3E3B0328C30D}"
+
+	for _, s := range []string{
+		want,
+		"54849625-5478-4994-A5BA-3E3B0328C30D",
+		"{54849625-5478-4994-a5ba-3e3b0328c30d}",
+	} {
+		g, err := ParseGuid(s)
+		if err != nil || g.String() != want {
+			t.Errorf("ParseGuid(%q) = %s, %v, want %s", s, g, err, want)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"{}",
+		"{54849625-5478-4994-A5BA3E3B0328C30D}",
+		"{54849625-5478-4994-A5BA-3E3B0328C30}",
+		"{54849625-5478-4994-A5BA-3E3B0328C30DD}",
+		"{5484962-55478-4994-A5BA-3E3B0328C30D}",
+		"{54849625-5478-4994-A5BA-3E3B0328C30Z}",
+		"{54849625-5478-4994-A5BA-3E3B0328C30D",
+		"{+4849625-5478-4994-A5BA-3E3B0328C30D}",
+	} {
+		if g, err := ParseGuid(s); err == nil {
+			t.Errorf("ParseGuid(%q) = %s, want error", s, g)
+		}
+	}
+}
+
+func TestGuidJSON(t *testing.T) {
+	g, err := ParseGuid(guidTests[0].s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []byte(`"` + guidTests[0].s + `"`); !bytes.Equal(data, want) {
+		t.Errorf("json.Marshal = %s, want %s", data, want)
+	}
+
+	v := Guid{}
+	if err := json.Unmarshal(data, &v); err != nil || !v.Equal(g) {
+		t.Errorf("json.Unmarshal(%s) = %s, %v", data, v, err)
+	}
+}
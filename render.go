@@ -0,0 +1,247 @@
+package evtxparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RenderOptions controls the XML output of RenderXML.
+type RenderOptions struct {
+	// Indent is repeated for every nesting level, elements are written on a
+	// single line when empty.
+	Indent string
+
+	// Declaration prepends the XML declaration.
+	Declaration bool
+
+	// OmitNamespaces drops xmlns attributes.
+	OmitNamespaces bool
+
+	// TimeFormat is the layout of time values, WindowsTimeFormat is used
+	// when empty.
+	TimeFormat string
+}
+
+// ErrNoElement is returned when rendering a nil element, like the document
+// of a stream whose template could not be resolved.
+var ErrNoElement = errors.New("no element to render")
+
+// RenderXML writes e and its descendants as XML to w. Nothing is written
+// when e is nil.
+func RenderXML(w io.Writer, e *Element, opts RenderOptions) error {
+	if e == nil {
+		return ErrNoElement
+	}
+
+	x := &xmlWriter{
+		w:    w,
+		opts: opts,
+	}
+
+	if opts.Declaration {
+		x.write(`<?xml version="1.0" encoding="utf-8"?>`)
+		x.newline()
+	}
+
+	x.element(e, 0)
+	x.newline()
+
+	return x.err
+}
+
+type xmlWriter struct {
+	w    io.Writer
+	opts RenderOptions
+	err  error
+}
+
+func (x *xmlWriter) write(s string) {
+	if x.err != nil {
+		return
+	}
+
+	_, x.err = io.WriteString(x.w, s)
+}
+
+func (x *xmlWriter) newline() {
+	if x.opts.Indent == "" {
+		return
+	}
+
+	x.write("\n")
+}
+
+func (x *xmlWriter) indent(depth int) {
+	x.write(strings.Repeat(x.opts.Indent, depth))
+}
+
+func (x *xmlWriter) format(v interface{}) string {
+	if x.opts.TimeFormat != "" {
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(x.opts.TimeFormat)
+		case FileTime:
+			if t.IsZero() || t.IsNever() {
+				return ""
+			}
+
+			return t.Time().Format(x.opts.TimeFormat)
+		}
+	}
+
+	return formatValue(v)
+}
+
+func (x *xmlWriter) attrs(attrs []*Attr) {
+	for _, a := range attrs {
+		if x.opts.OmitNamespaces && (a.Name == "xmlns" || strings.HasPrefix(a.Name, "xmlns:")) {
+			continue
+		}
+
+		x.write(" ")
+		x.write(xmlName(a.Name))
+		x.write(`="`)
+		x.write(escapeXML(x.format(a.Value), true))
+		x.write(`"`)
+	}
+}
+
+func (x *xmlWriter) element(e *Element, depth int) {
+	x.indent(depth)
+
+	name := xmlName(e.Name)
+
+	x.write("<")
+	x.write(name)
+	x.attrs(e.Attrs)
+
+	if len(e.Children) == 0 {
+		x.write("/>")
+		return
+	}
+
+	x.write(">")
+
+	// elements with character data only are written on a single line
+	nested := len(e.Elements()) > 0
+
+	for _, child := range e.Children {
+		switch child := child.(type) {
+		case *Element:
+			x.newline()
+			x.element(child, depth+1)
+		case *Text:
+			if nested {
+				x.newline()
+				x.indent(depth + 1)
+			}
+
+			x.write(escapeXML(x.format(child.Value), false))
+		case *CData:
+			x.write("<![CDATA[")
+			x.write(strings.Replace(xmlChars(child.Text), "]]>", "]]]]><![CDATA[>", -1))
+			x.write("]]>")
+		case *Entity:
+			// undeclared entities would make the document ill-formed
+			if _, ok := predefinedEntities[child.Name]; ok {
+				x.write("&" + child.Name + ";")
+			} else {
+				x.write(escapeXML(child.String(), false))
+			}
+		case *ProcInst:
+			x.write("<?")
+			x.write(xmlName(child.Target))
+			if child.Data != "" {
+				x.write(" ")
+				x.write(strings.Replace(xmlChars(child.Data), "?>", "? >", -1))
+			}
+			x.write("?>")
+		}
+	}
+
+	if nested {
+		x.newline()
+		x.indent(depth)
+	}
+
+	x.write("</")
+	x.write(name)
+	x.write(">")
+}
+
+// escapeXML escapes s for use as character data or, when attr is set, as a
+// double quoted attribute value. Characters that are not allowed in XML,
+// including invalid UTF-8, are replaced by U+FFFD.
+func escapeXML(s string, attr bool) string {
+	b := bytes.Buffer{}
+
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '"' && attr:
+			b.WriteString("&quot;")
+		case r == '\r':
+			b.WriteString("&#xD;")
+		case (r == '\n' || r == '\t') && attr:
+			fmt.Fprintf(&b, "&#x%X;", r)
+		case !isXMLChar(r):
+			b.WriteRune(utf8.RuneError)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// xmlChars replaces characters that are not allowed in XML by U+FFFD.
+func xmlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if !isXMLChar(r) {
+			return utf8.RuneError
+		}
+
+		return r
+	}, s)
+}
+
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0a || r == 0x0d ||
+		r >= 0x20 && r <= 0xd7ff ||
+		r >= 0xe000 && r <= 0xfffd ||
+		r >= 0x10000 && r <= 0x10ffff
+}
+
+// xmlName replaces characters that are not valid in an XML name, names come
+// from the chunk data and are not trusted.
+func xmlName(name string) string {
+	b := bytes.Buffer{}
+
+	for i, r := range name {
+		switch {
+		case r == '_' || r == ':' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			r = '_'
+		}
+
+		b.WriteRune(r)
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
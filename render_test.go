@@ -0,0 +1,18 @@
+package evtxparser
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRenderXMLNil(t *testing.T) {
+	b := bytes.Buffer{}
+
+	if err := RenderXML(&b, nil, RenderOptions{Declaration: true}); err != ErrNoElement {
+		t.Errorf("got %v, want ErrNoElement", err)
+	}
+
+	if b.Len() != 0 {
+		t.Errorf("wrote %q for a nil element", b.String())
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/dutchcoders/evtxparser"
+	"github.com/dutchcoders/evtxparser/tamper"
+)
+
+func main() {
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+
+	defer f.Close()
+
+	ef, err := evtxparser.Open(f)
+	if err != nil {
+		panic(err)
+	}
+
+	report, err := tamper.Analyze(ef)
+	if err != nil {
+		panic(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(report); err != nil {
+		panic(err)
+	}
+}
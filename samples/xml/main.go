@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
 
 	"github.com/dutchcoders/evtxparser"
@@ -13,11 +12,19 @@ func main() {
 		panic(err)
 	}
 
-	data, err := ioutil.ReadAll(f)
+	defer f.Close()
+
+	ef, err := evtxparser.Open(f)
 	if err != nil {
 		panic(err)
 	}
 
-	evtxparser.Parse(data)
-	return
+	records := ef.Records()
+	for records.Next() {
+		records.Record().Stream.Dump()
+	}
+
+	if err := records.Err(); err != nil {
+		panic(err)
+	}
 }
@@ -1,6 +1,10 @@
 package evtxparser
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type Sid struct {
 	Revision            uint8
@@ -17,7 +21,13 @@ func (g Sid) String() string {
 		v = v << 8
 		v += uint64(ia)
 	}
-	s += fmt.Sprintf("-%d", v)
+
+	// authorities that do not fit 32 bits are written in hex
+	if v >= 1<<32 {
+		s += fmt.Sprintf("-0x%012X", v)
+	} else {
+		s += fmt.Sprintf("-%d", v)
+	}
 
 	for _, sa := range g.SubAuthority {
 		s += fmt.Sprintf("-%d", sa)
@@ -25,3 +35,88 @@ func (g Sid) String() string {
 
 	return s
 }
+
+func (g Sid) Equal(other Sid) bool {
+	if g.Revision != other.Revision || g.IdentifierAuthority != other.IdentifierAuthority || len(g.SubAuthority) != len(other.SubAuthority) {
+		return false
+	}
+
+	for i := range g.SubAuthority {
+		if g.SubAuthority[i] != other.SubAuthority[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (g Sid) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+func (g *Sid) UnmarshalText(text []byte) error {
+	v, err := ParseSid(string(text))
+	if err != nil {
+		return err
+	}
+
+	*g = v
+	return nil
+}
+
+// ParseSid parses a sid in string format, S-R-I-S-S...
+func ParseSid(s string) (Sid, error) {
+	sid := Sid{}
+
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || len(parts) > 3+255 || (parts[0] != "S" && parts[0] != "s") {
+		return sid, fmt.Errorf("invalid sid %q", s)
+	}
+
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return sid, fmt.Errorf("invalid sid %q", s)
+	}
+
+	// decimal or, for large authorities, hexadecimal with 0x prefix
+	var authority uint64
+	if strings.HasPrefix(parts[2], "0x") || strings.HasPrefix(parts[2], "0X") {
+		authority, err = strconv.ParseUint(parts[2][2:], 16, 48)
+	} else {
+		authority, err = strconv.ParseUint(parts[2], 10, 48)
+	}
+
+	if err != nil {
+		return sid, fmt.Errorf("invalid sid %q", s)
+	}
+
+	sid.Revision = uint8(revision)
+	for i := 5; i >= 0; i-- {
+		sid.IdentifierAuthority[i] = uint8(authority)
+		authority = authority >> 8
+	}
+
+	sid.SubAuthority = make([]uint32, len(parts)-3)
+	for i, part := range parts[3:] {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return sid, fmt.Errorf("invalid sid %q", s)
+		}
+
+		sid.SubAuthority[i] = uint32(v)
+	}
+
+	sid.SubAuthorityCount = uint8(len(sid.SubAuthority))
+	return sid, nil
+}
+
+func (sid *Sid) Decode(d Decoder) {
+	sid.Revision = d.Uint8()
+	sid.SubAuthorityCount = d.Uint8()
+	d.Copy(sid.IdentifierAuthority[:])
+
+	sid.SubAuthority = make([]uint32, sid.SubAuthorityCount)
+	for i := uint8(0); i < sid.SubAuthorityCount; i++ {
+		sid.SubAuthority[i] = d.Uint32()
+	}
+}
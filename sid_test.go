@@ -0,0 +1,113 @@
+package evtxparser
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+var sidTests = []struct {
+	data []byte
+	s    string
+}{
+	// LocalSystem
+	{[]byte{1, 1, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0}, "S-1-5-18"},
+	// BUILTIN\Administrators
+	{[]byte{1, 2, 0, 0, 0, 0, 0, 5, 32, 0, 0, 0, 0x20, 0x02, 0, 0}, "S-1-5-32-544"},
+	// Everyone
+	{[]byte{1, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}, "S-1-1-0"},
+	// domain account
+	{[]byte{1, 5, 0, 0, 0, 0, 0, 5, 0x15, 0, 0, 0, 0xc7, 0xf7, 0xfe, 0xd7, 0x7c, 0x77, 0x55, 0xc8, 0x94, 0x5a, 0xce, 0x01, 0xf5, 0x03, 0, 0}, "S-1-5-21-3623811015-3361044348-30300820-1013"},
+	// authorities of 2^32 and up are written in hex
+	{[]byte{1, 1, 0, 1, 0, 0, 0, 0, 7, 0, 0, 0}, "S-1-0x000100000000-7"},
+	{[]byte{1, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "S-1-0xFFFFFFFFFFFF"},
+}
+
+func TestSid(t *testing.T) {
+	for _, test := range sidTests {
+		sid := Sid{}
+
+		d := NewDefaultDecoder(test.data, binary.LittleEndian)
+		sid.Decode(d)
+		if err := d.LastError(); err != nil {
+			t.Fatalf("Decode(% x): %v", test.data, err)
+		}
+
+		if s := sid.String(); s != test.s {
+			t.Errorf("String() of % x = %s, want %s", test.data, s, test.s)
+		}
+
+		text, err := sid.MarshalText()
+		if err != nil || string(text) != test.s {
+			t.Errorf("MarshalText() of % x = %s, %v, want %s", test.data, text, err, test.s)
+		}
+
+		v := Sid{}
+		if err := v.UnmarshalText([]byte(test.s)); err != nil || !v.Equal(sid) {
+			t.Errorf("UnmarshalText(%s) = %s, %v, want %s", test.s, v, err, sid)
+		}
+
+		if v.SubAuthorityCount != sid.SubAuthorityCount {
+			t.Errorf("UnmarshalText(%s) has %d sub authorities, want %d", test.s, v.SubAuthorityCount, sid.SubAuthorityCount)
+		}
+	}
+}
+
+func TestParseSid(t *testing.T) {
+	for s, want := range map[string]string{
+		"s-1-5-18":              "S-1-5-18",
+		"S-1-0X000100000000-7":  "S-1-0x000100000000-7",
+		"S-1-0x5-18":            "S-1-5-18",
+		"S-1-4294967296-1":      "S-1-0x000100000000-1",
+		"S-1-010-21":            "S-1-10-21",
+		"S-1-5-21-0-4294967295": "S-1-5-21-0-4294967295",
+	} {
+		sid, err := ParseSid(s)
+		if err != nil || sid.String() != want {
+			t.Errorf("ParseSid(%q) = %s, %v, want %s", s, sid, err, want)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"S-1",
+		"X-1-5-18",
+		"S--5-18",
+		"S-256-5-18",
+		"S-1-0b101-21",
+		"S-1-0o7-21",
+		"S-1-1_0-21",
+		"S-1-+5-21",
+		"S-1-0x-21",
+		"S-1-0x1000000000000-21",
+		"S-1-281474976710656-21",
+		"S-1-5-4294967296",
+		"S-1-5-0x12",
+		"S-1-5-18-",
+	} {
+		if sid, err := ParseSid(s); err == nil {
+			t.Errorf("ParseSid(%q) = %s, want error", s, sid)
+		}
+	}
+}
+
+func TestSidJSON(t *testing.T) {
+	sid, err := ParseSid(sidTests[3].s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `"` + sidTests[3].s + `"`; string(data) != want {
+		t.Errorf("json.Marshal = %s, want %s", data, want)
+	}
+
+	v := Sid{}
+	if err := json.Unmarshal(data, &v); err != nil || !v.Equal(sid) {
+		t.Errorf("json.Unmarshal(%s) = %s, %v", data, v, err)
+	}
+}
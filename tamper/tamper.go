@@ -0,0 +1,225 @@
+// Package tamper looks for signs that an evtx file was edited or cleared.
+package tamper
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dutchcoders/evtxparser"
+)
+
+// Kind identifies the type of a finding.
+type Kind string
+
+const (
+	KindChecksum          Kind = "checksum-mismatch"
+	KindDecodeError       Kind = "decode-error"
+	KindChunkRange        Kind = "chunk-range-mismatch"
+	KindRecordIDGap       Kind = "record-id-gap"
+	KindRecordIDDuplicate Kind = "record-id-duplicate"
+	KindRecordIDMismatch  Kind = "record-id-mismatch"
+	KindTimeBackwards     Kind = "time-backwards"
+	KindTimeBeforeCreated Kind = "time-before-created"
+	KindLogCleared        Kind = "log-cleared"
+	KindMergedRecord      Kind = "merged-record"
+)
+
+// Finding is a single sign of tampering. Chunk is -1 for the file header.
+type Finding struct {
+	Kind     Kind   `json:"kind"`
+	Chunk    int    `json:"chunk"`
+	Offset   int64  `json:"offset"`
+	RecordID uint64 `json:"record_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Report holds the findings of a file in order of discovery.
+type Report struct {
+	Chunks   int       `json:"chunks"`
+	Records  int       `json:"records"`
+	Findings []Finding `json:"findings"`
+}
+
+// Tampered reports whether anything was found.
+func (r *Report) Tampered() bool {
+	return len(r.Findings) > 0
+}
+
+func (r *Report) add(kind Kind, chunk int, offset int64, recordID uint64, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{
+		Kind:     kind,
+		Chunk:    chunk,
+		Offset:   offset,
+		RecordID: recordID,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// record is what is kept of every record for the checks across chunks.
+type record struct {
+	id     uint64
+	time   time.Time
+	chunk  int
+	offset int64
+}
+
+// Analyze verifies the checksums of f and walks all of its records.
+func Analyze(f *evtxparser.File) (*Report, error) {
+	report := &Report{
+		Findings: []Finding{},
+	}
+
+	v, err := f.Verify()
+	if err != nil {
+		return nil, err
+	}
+
+	if !v.Header.Valid() {
+		report.add(KindChecksum, -1, 0, 0, "file header checksum %#08x, computed %#08x", v.Header.Stored, v.Header.Computed)
+	}
+
+	for _, cv := range v.Chunks {
+		switch {
+		case cv.Err != nil:
+			report.add(KindDecodeError, cv.Index, cv.Offset, 0, "%s", cv.Err)
+		case !cv.Header.Valid():
+			report.add(KindChecksum, cv.Index, cv.Offset, 0, "chunk header checksum %#08x, computed %#08x", cv.Header.Stored, cv.Header.Computed)
+		}
+
+		if cv.Err == nil && !cv.Records.Valid() {
+			report.add(KindChecksum, cv.Index, cv.Offset, 0, "event records checksum %#08x, computed %#08x", cv.Records.Stored, cv.Records.Computed)
+		}
+	}
+
+	records := []record{}
+
+	for i := 0; i < f.ChunkCount(); i++ {
+		ch, err := f.Chunk(i)
+		if err != nil {
+			// reported by the checksum verification
+			continue
+		}
+
+		report.Chunks++
+		records = append(records, analyzeChunk(report, ch)...)
+	}
+
+	report.Records = len(records)
+
+	// chunks are reused when the log wraps, the file order is not the
+	// order of the records
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].id < records[j].id
+	})
+
+	for i := 1; i < len(records); i++ {
+		prev, r := records[i-1], records[i]
+
+		switch {
+		case r.id == prev.id:
+			report.add(KindRecordIDDuplicate, r.chunk, r.offset, r.id, "record %d also at chunk %d offset %#x", r.id, prev.chunk, prev.offset)
+		case r.id == prev.id+2:
+			report.add(KindRecordIDGap, r.chunk, r.offset, r.id, "record %d is missing", prev.id+1)
+		case r.id > prev.id+2:
+			report.add(KindRecordIDGap, r.chunk, r.offset, r.id, "records %d to %d are missing", prev.id+1, r.id-1)
+		}
+
+		if r.time.Before(prev.time) {
+			report.add(KindTimeBackwards, r.chunk, r.offset, r.id, "record time %s is before %s of record %d", format(r.time), format(prev.time), prev.id)
+		}
+	}
+
+	return report, nil
+}
+
+// analyzeChunk checks the records of a chunk against each other and against
+// the chunk header.
+func analyzeChunk(report *Report, ch *evtxparser.Chunk) []record {
+	records := []record{}
+
+	// the record that hides the recovered records following it
+	var merged *evtxparser.Record
+
+	it := ch.Records()
+	for it.Next() {
+		r := it.Record()
+
+		if !r.Recovered {
+			merged = r
+		} else if merged != nil {
+			report.add(KindMergedRecord, r.Chunk, r.Offset, r.RecordID, "recovered from merged record %d", merged.RecordID)
+		}
+
+		records = append(records, record{
+			id:     r.RecordID,
+			time:   r.Time.Time(),
+			chunk:  r.Chunk,
+			offset: r.Offset,
+		})
+
+		ev := r.Event()
+		if ev == nil {
+			continue
+		}
+
+		if ev.EventRecordID != 0 && ev.EventRecordID != r.RecordID {
+			report.add(KindRecordIDMismatch, r.Chunk, r.Offset, r.RecordID, "EventRecordID is %d", ev.EventRecordID)
+		}
+
+		// records are written after the event was created
+		if !ev.TimeCreated.IsZero() && r.Time.Time().Before(ev.TimeCreated) {
+			report.add(KindTimeBeforeCreated, r.Chunk, r.Offset, r.RecordID, "record time %s is before TimeCreated %s", r.Time, format(ev.TimeCreated))
+		}
+
+		if isLogCleared(r, ev) {
+			user, _ := ev.UserData.Get("SubjectUserName")
+			report.add(KindLogCleared, r.Chunk, r.Offset, r.RecordID, "event %d at %s by %v", ev.EventID, r.Time, user)
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		report.add(KindDecodeError, ch.Index, ch.Offset, 0, "%s", err)
+	}
+
+	if len(records) == 0 {
+		return records
+	}
+
+	first, last := records[0].id, records[0].id
+	for _, r := range records {
+		if r.id < first {
+			first = r.id
+		}
+
+		if r.id > last {
+			last = r.id
+		}
+	}
+
+	if first != ch.Header.FirstRecordID || last != ch.Header.LastRecordID {
+		report.add(KindChunkRange, ch.Index, ch.Offset, 0, "header records %d to %d, found %d to %d", ch.Header.FirstRecordID, ch.Header.LastRecordID, first, last)
+	}
+
+	return records
+}
+
+// isLogCleared reports whether the event is a Security 1102 or System 104
+// log clear event.
+func isLogCleared(r *evtxparser.Record, ev *evtxparser.Event) bool {
+	if ev.EventID != 1102 && ev.EventID != 104 {
+		return false
+	}
+
+	switch ev.Provider.Name {
+	case "Microsoft-Windows-Eventlog", "Eventlog":
+		return true
+	}
+
+	doc := r.Stream.Document()
+	return doc != nil && doc.Find("UserData/LogFileCleared") != nil
+}
+
+func format(t time.Time) string {
+	return t.UTC().Format(evtxparser.WindowsTimeFormat)
+}
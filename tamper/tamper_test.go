@@ -0,0 +1,145 @@
+package tamper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/dutchcoders/evtxparser"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func analyze(t *testing.T, data []byte) *Report {
+	f, err := evtxparser.Open(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Analyze(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report
+}
+
+// recordOffset returns the file offset of the record with the given id in
+// the first chunk.
+func recordOffset(t *testing.T, data []byte, id uint64) int {
+	chunk := data[evtxparser.HeaderSize : evtxparser.HeaderSize+evtxparser.ChunkSize]
+	free := int(binary.LittleEndian.Uint32(chunk[48:]))
+
+	for p := 512; p+24 <= free; {
+		if binary.LittleEndian.Uint64(chunk[p+8:]) == id {
+			return evtxparser.HeaderSize + p
+		}
+
+		p += int(binary.LittleEndian.Uint32(chunk[p+4:]))
+	}
+
+	t.Fatalf("record %d not found", id)
+	return 0
+}
+
+// updateChecksums recomputes the checksums of the first chunk.
+func updateChecksums(data []byte) {
+	chunk := data[evtxparser.HeaderSize : evtxparser.HeaderSize+evtxparser.ChunkSize]
+	free := binary.LittleEndian.Uint32(chunk[48:])
+	binary.LittleEndian.PutUint32(chunk[52:], crc32.ChecksumIEEE(chunk[512:free]))
+
+	h := crc32.NewIEEE()
+	h.Write(chunk[:120])
+	h.Write(chunk[128:512])
+	binary.LittleEndian.PutUint32(chunk[124:], h.Sum32())
+}
+
+func kinds(report *Report) map[Kind]int {
+	m := map[Kind]int{}
+	for _, f := range report.Findings {
+		m[f.Kind]++
+	}
+
+	return m
+}
+
+func TestAnalyzeMerged(t *testing.T) {
+	report := analyze(t, readFixture(t, "merged.evtx"))
+
+	if report.Chunks != 1 || report.Records != 11 {
+		t.Errorf("got %d chunks, %d records, want 1, 11", report.Chunks, report.Records)
+	}
+
+	want := []struct {
+		kind Kind
+		id   uint64
+	}{
+		{KindMergedRecord, 4},
+		{KindMergedRecord, 5},
+		{KindLogCleared, 10},
+		{KindTimeBackwards, 8},
+	}
+
+	if len(report.Findings) != len(want) {
+		t.Fatalf("got findings %+v, want %v", report.Findings, want)
+	}
+
+	for i, w := range want {
+		if f := report.Findings[i]; f.Kind != w.kind || f.RecordID != w.id {
+			t.Errorf("finding %d: got %s of record %d, want %s of record %d", i, f.Kind, f.RecordID, w.kind, w.id)
+		}
+	}
+}
+
+func TestAnalyzePatched(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch func(t *testing.T, data []byte)
+		kind  Kind
+		want  int
+	}{
+		{"unmodified", func(t *testing.T, data []byte) {}, KindTimeBeforeCreated, 0},
+		{"time-before-created", func(t *testing.T, data []byte) {
+			// one second before the event was created, but not before
+			// the previous record
+			p := recordOffset(t, data, 2)
+			ft := binary.LittleEndian.Uint64(data[p+16:])
+			binary.LittleEndian.PutUint64(data[p+16:], ft-10000000)
+			updateChecksums(data)
+		}, KindTimeBeforeCreated, 1},
+		{"checksum", func(t *testing.T, data []byte) {
+			p := recordOffset(t, data, 2)
+			data[p+16]++
+		}, KindChecksum, 1},
+		{"gap", func(t *testing.T, data []byte) {
+			p := recordOffset(t, data, 11)
+			binary.LittleEndian.PutUint64(data[p+8:], 20)
+			updateChecksums(data)
+		}, KindRecordIDGap, 1},
+		{"duplicate", func(t *testing.T, data []byte) {
+			p := recordOffset(t, data, 11)
+			binary.LittleEndian.PutUint64(data[p+8:], 10)
+			updateChecksums(data)
+		}, KindRecordIDDuplicate, 1},
+	}
+
+	for _, test := range tests {
+		data := readFixture(t, "records.evtx")
+		test.patch(t, data)
+
+		report := analyze(t, data)
+		if n := kinds(report)[test.kind]; n != test.want {
+			t.Errorf("%s: got %d %s findings, want %d: %+v", test.name, n, test.kind, test.want, report.Findings)
+		}
+	}
+}
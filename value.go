@@ -0,0 +1,129 @@
+package evtxparser
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// utf16String decodes little endian UTF-16, invalid surrogates are replaced
+// by U+FFFD.
+func utf16String(data []byte) string {
+	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+	if val, err := utf16.Bytes(data); err == nil {
+		return string(val)
+	}
+
+	return ""
+}
+
+// ByteArray is the value of a Byte array substitution. Binary data is a
+// single []byte value, the items of a ByteArray are separate values: an
+// element is repeated for every item and text joins them as decimal numbers.
+type ByteArray []uint8
+
+// SizeT is a pointer sized integer.
+type SizeT uint64
+
+func (v SizeT) String() string {
+	return fmt.Sprintf("0x%x", uint64(v))
+}
+
+// HexInt32 is a 32 bit integer that is displayed in hexadecimal.
+type HexInt32 uint32
+
+func (v HexInt32) String() string {
+	return fmt.Sprintf("0x%x", uint32(v))
+}
+
+// HexInt64 is a 64 bit integer that is displayed in hexadecimal.
+type HexInt64 uint64
+
+func (v HexInt64) String() string {
+	return fmt.Sprintf("0x%x", uint64(v))
+}
+
+// EvtHandle is the value of a handle of the system that wrote the event.
+type EvtHandle uint64
+
+func (v EvtHandle) String() string {
+	return fmt.Sprintf("0x%x", uint64(v))
+}
+
+// formatValue returns the textual representation of a substitution value.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "true"
+		} else {
+			return "false"
+		}
+	case uint64:
+		return fmt.Sprintf("%d", v)
+	case int64:
+		return fmt.Sprintf("%d", v)
+	case uint32:
+		return fmt.Sprintf("%d", v)
+	case int32:
+		return fmt.Sprintf("%d", v)
+	case uint16:
+		return fmt.Sprintf("%d", v)
+	case int16:
+		return fmt.Sprintf("%d", v)
+	case uint8:
+		return fmt.Sprintf("%d", v)
+	case int8:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return fmt.Sprintf("%X", v)
+	case string:
+		return v
+	case Stream:
+		if doc := v.Document(); doc != nil {
+			return doc.Text()
+		}
+
+		return ""
+	case Sid:
+		return v.String()
+	case Guid:
+		return v.String()
+	case SizeT:
+		return v.String()
+	case HexInt32:
+		return v.String()
+	case HexInt64:
+		return v.String()
+	case EvtHandle:
+		return v.String()
+	case FileTime:
+		return v.String()
+	case time.Time:
+		return v.UTC().Format(WindowsTimeFormat)
+	}
+
+	// array items are separated by a comma
+	if isArrayValue(v) {
+		rv := reflect.ValueOf(v)
+
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = formatValue(rv.Index(i).Interface())
+		}
+
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprintf("%#v", v)
+}
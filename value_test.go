@@ -0,0 +1,95 @@
+package evtxparser
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeArray(t *testing.T) {
+	tests := []struct {
+		t       Type
+		ptrSize int
+		data    []byte
+		want    interface{}
+		text    string
+	}{
+		{EvtVarTypeSizeT, 0, []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}, []SizeT{1, 2}, "0x1,0x2"},
+		{EvtVarTypeSizeT, 0, []byte{1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0}, []SizeT{1, 2, 3}, "0x1,0x2,0x3"},
+		// without a known pointer size a multiple of 8 is taken as 64 bit
+		{EvtVarTypeSizeT, 0, []byte{1, 0, 0, 0, 2, 0, 0, 0}, []SizeT{0x200000001}, "0x200000001"},
+		{EvtVarTypeSizeT, 4, []byte{1, 0, 0, 0, 2, 0, 0, 0}, []SizeT{1, 2}, "0x1,0x2"},
+		{EvtVarTypeSizeT, 8, []byte{1, 0, 0, 0, 2, 0, 0, 0}, []SizeT{0x200000001}, "0x200000001"},
+		{EvtVarTypeByte, 0, []byte{1, 2, 255}, ByteArray{1, 2, 255}, "1,2,255"},
+		{EvtVarTypeSByte, 0, []byte{1, 255}, []int8{1, -1}, "1,-1"},
+		{EvtVarTypeUInt16, 0, []byte{1, 0, 2, 0}, []uint16{1, 2}, "1,2"},
+	}
+
+	for _, test := range tests {
+		d := NewDefaultDecoder(test.data, binary.LittleEndian)
+
+		v := decodeArray(d, test.t, len(test.data), test.ptrSize, nil)
+		if err := d.LastError(); err != nil {
+			t.Fatalf("%s array: %v", test.t, err)
+		}
+
+		if !reflect.DeepEqual(v, test.want) {
+			t.Errorf("%s array = %#v, want %#v", test.t, v, test.want)
+		}
+
+		if !isArrayValue(v) {
+			t.Errorf("%s array is not an array value", test.t)
+		}
+
+		if text := formatValue(v); text != test.text {
+			t.Errorf("%s array = %q, want %q", test.t, text, test.text)
+		}
+	}
+}
+
+// TestSubstitutionArrayPointerSize decodes a SizeT array of a 32 bit system,
+// the pointer size follows from a scalar value of the same array.
+func TestSubstitutionArrayPointerSize(t *testing.T) {
+	for _, scalar := range []Type{EvtVarTypeSizeT, EvtVarTypeEvtHandle} {
+		data := []byte{2, 0, 0, 0}
+		data = append(data, 8, 0, byte(EvtVarTypeSizeT|EvtVarTypeArray), 0)
+		data = append(data, 4, 0, byte(scalar), 0)
+		data = append(data, 1, 0, 0, 0, 2, 0, 0, 0)
+		data = append(data, 3, 0, 0, 0)
+
+		sa := SubstitutionArray{}
+
+		d := NewDefaultDecoder(data, binary.LittleEndian)
+		sa.Decode(d, nil)
+		if err := d.LastError(); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []SizeT{1, 2}; !reflect.DeepEqual(sa[0], want) {
+			t.Errorf("with %s: array = %#v, want %#v", scalar, sa[0], want)
+		}
+	}
+}
+
+func TestFormatHexValue(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{HexInt32(0x3e7), "0x3e7"},
+		{HexInt32(0xc000006d), "0xc000006d"},
+		{HexInt32(0), "0x0"},
+		{HexInt64(0x8020000000000000), "0x8020000000000000"},
+		{HexInt64(0xabcdef), "0xabcdef"},
+		{SizeT(0x7ff6aa), "0x7ff6aa"},
+		{SizeT(0xfffff80002a4b000), "0xfffff80002a4b000"},
+		{EvtHandle(0x1a4), "0x1a4"},
+		{[]HexInt32{0x3e7, 0xc000006d}, "0x3e7,0xc000006d"},
+	}
+
+	for _, test := range tests {
+		if s := formatValue(test.v); s != test.want {
+			t.Errorf("formatValue(%#v) = %q, want %q", test.v, s, test.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package evtxparser
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Checksum is a CRC32 as stored in the file and as computed over its data.
+type Checksum struct {
+	Stored   uint32
+	Computed uint32
+}
+
+func (c Checksum) Valid() bool {
+	return c.Stored == c.Computed
+}
+
+// ChunkVerification holds the checksums of a single chunk. Err is set when
+// the chunk could not be read.
+type ChunkVerification struct {
+	Index  int
+	Offset int64
+
+	Header  Checksum
+	Records Checksum
+
+	Err error
+}
+
+func (v ChunkVerification) Valid() bool {
+	return v.Err == nil && v.Header.Valid() && v.Records.Valid()
+}
+
+// Verification is the result of verifying the checksums of a file.
+type Verification struct {
+	Header Checksum
+	Chunks []ChunkVerification
+}
+
+// Valid reports whether the file header and all chunks have valid checksums.
+func (v *Verification) Valid() bool {
+	if !v.Header.Valid() {
+		return false
+	}
+
+	for _, cv := range v.Chunks {
+		if !cv.Valid() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Verify checks the CRC32 of the file header, and the header and event
+// records checksums of every chunk. Mismatches are reported per chunk, the
+// error is only set when the file header cannot be read.
+func (f *File) Verify() (*Verification, error) {
+	buff := make([]byte, 128)
+	if err := readAt(f.r, buff, 0, -1); err != nil {
+		return nil, err
+	}
+
+	v := &Verification{
+		Header: Checksum{
+			Stored:   binary.LittleEndian.Uint32(buff[124:128]),
+			Computed: crc32.ChecksumIEEE(buff[:120]),
+		},
+	}
+
+	for i := 0; i < f.ChunkCount(); i++ {
+		cv := ChunkVerification{
+			Index:  i,
+			Offset: f.ChunkOffset(i),
+		}
+
+		if data, err := f.readChunk(i); err != nil {
+			cv.Err = err
+		} else {
+			cv.Header, cv.Records = verifyChunk(data)
+		}
+
+		v.Chunks = append(v.Chunks, cv)
+	}
+
+	return v, nil
+}
+
+// Verify checks the header and event records checksums of the chunk.
+func (ch *Chunk) Verify() ChunkVerification {
+	cv := ChunkVerification{
+		Index:  ch.Index,
+		Offset: ch.Offset,
+	}
+
+	cv.Header, cv.Records = verifyChunk(ch.data)
+	return cv
+}
+
+// verifyChunk computes the chunk header checksum over bytes 0-120 and
+// 128-512, and the records checksum over the records up to the free space
+// offset.
+func verifyChunk(data []byte) (header Checksum, records Checksum) {
+	if len(data) < 512 {
+		return
+	}
+
+	h := crc32.NewIEEE()
+	h.Write(data[0:120])
+	h.Write(data[128:512])
+
+	header = Checksum{
+		Stored:   binary.LittleEndian.Uint32(data[124:128]),
+		Computed: h.Sum32(),
+	}
+
+	free := int(binary.LittleEndian.Uint32(data[48:52]))
+	if free < 512 || free > len(data) {
+		free = len(data)
+	}
+
+	records = Checksum{
+		Stored:   binary.LittleEndian.Uint32(data[52:56]),
+		Computed: crc32.ChecksumIEEE(data[512:free]),
+	}
+
+	return
+}